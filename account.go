@@ -0,0 +1,115 @@
+package onedriveclient
+
+import (
+	"context"
+	"github.com/koofr/go-httpclient"
+)
+
+// UserInfo is the signed-in user.
+type UserInfo struct {
+	Id   string
+	Name string
+	// Email is the user's preferred address, empty if not shared.
+	Email string
+}
+
+// DriveInfo is the signed-in user's drive.
+type DriveInfo struct {
+	Id string
+	// Type is "personal", "business" or "documentLibrary". The Live API
+	// only serves personal drives.
+	Type string
+}
+
+func (d *OneDrive) Me() (user UserInfo, err error) {
+	return d.MeContext(context.Background())
+}
+
+// MeContext returns the signed-in user. With BackendGraph this needs the
+// User.Read scope.
+func (d *OneDrive) MeContext(ctx context.Context) (user UserInfo, err error) {
+	defer wrapOp(&err, "Me", "me")
+	header, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+
+	resp := &struct {
+		Id string `json:"id"`
+		// Live
+		Name   string `json:"name"`
+		Emails struct {
+			Preferred string `json:"preferred"`
+		} `json:"emails"`
+		// Graph
+		DisplayName       string `json:"displayName"`
+		Mail              string `json:"mail"`
+		UserPrincipalName string `json:"userPrincipalName"`
+	}{}
+
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         "GET",
+		Path:           "/me",
+		Headers:        header,
+		ExpectedStatus: []int{200},
+		RespEncoding:   httpclient.EncodingJSON,
+		RespValue:      resp,
+	}
+	if _, err = d.request(ctx, d.ApiClient, req); err != nil {
+		return
+	}
+
+	if d.graph() {
+		user = UserInfo{Id: resp.Id, Name: resp.DisplayName, Email: resp.Mail}
+		if user.Email == "" {
+			user.Email = resp.UserPrincipalName
+		}
+		return
+	}
+	user = UserInfo{Id: resp.Id, Name: resp.Name, Email: resp.Emails.Preferred}
+	return
+}
+
+func (d *OneDrive) DriveInfo() (drive DriveInfo, err error) {
+	return d.DriveInfoContext(context.Background())
+}
+
+// DriveInfoContext returns the signed-in user's drive. The Live API has no
+// drive ids; the id of the root folder is returned instead.
+func (d *OneDrive) DriveInfoContext(ctx context.Context) (drive DriveInfo, err error) {
+	defer wrapOp(&err, "DriveInfo", "drive")
+	if !d.graph() {
+		var root NodeInfo
+		if root, err = d.RootInfoContext(ctx); err != nil {
+			return
+		}
+		return DriveInfo{Id: root.Id, Type: "personal"}, nil
+	}
+
+	header, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+
+	resp := &struct {
+		Id        string `json:"id"`
+		DriveType string `json:"driveType"`
+	}{}
+
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         "GET",
+		Path:           "/me/drive",
+		Headers:        header,
+		ExpectedStatus: []int{200},
+		RespEncoding:   httpclient.EncodingJSON,
+		RespValue:      resp,
+	}
+	if _, err = d.request(ctx, d.ApiClient, req); err != nil {
+		return
+	}
+
+	drive = DriveInfo{Id: resp.Id, Type: resp.DriveType}
+	return
+}
@@ -0,0 +1,513 @@
+package onedriveclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	authorizeUrl = "https://login.live.com/oauth20_authorize.srf"
+	tokenUrl     = "https://login.live.com/oauth20_token.srf"
+
+	// GraphTokenURL is the Microsoft identity platform (v2.0) token
+	// endpoint to refresh tokens for BackendGraph against.
+	GraphTokenURL = "https://login.microsoftonline.com/common/oauth2/v2.0/token"
+	// GraphAuthorizeURL is the v2.0 consent page for BackendGraph.
+	GraphAuthorizeURL = "https://login.microsoftonline.com/common/oauth2/v2.0/authorize"
+
+	defaultRefreshBefore = 60 * time.Second
+	// defaultTokenLifetime is assumed for tokens sent without expires_in.
+	defaultTokenLifetime = 5 * time.Minute
+
+	// DefaultTokenTimeout bounds token requests unless
+	// OneDriveAuth.TokenTimeout is set.
+	DefaultTokenTimeout = 30 * time.Second
+
+	// DefaultUserAgent is sent as the User-Agent unless one is configured.
+	DefaultUserAgent = "go-onedriveclient/1.0"
+)
+
+type OneDriveAuth struct {
+	ClientId     string
+	ClientSecret string
+	RedirectUri  string
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+
+	// TokenURL is the OAuth token endpoint used for refreshing. It defaults
+	// to the login.live.com endpoint when empty.
+	TokenURL string
+	// Scopes, if set, are sent as the scope parameter on refresh. The v2.0
+	// endpoint requires them.
+	Scopes []string
+	// GrantedScopes are the scopes of the access token as reported by the
+	// token endpoint. They are updated on every refresh that reports them.
+	GrantedScopes []string
+	// RequiredScopes, if set, makes ValidToken fail with a *ScopeError
+	// while GrantedScopes lack any of them, so that missing permissions
+	// surface before the first request that needs them. Scopes are
+	// compared case-insensitively and without a resource prefix such as
+	// "https://graph.microsoft.com/". Only scopes the token endpoint
+	// reports, such as "Files.ReadWrite.All", can be required; it does
+	// not report offline_access.
+	RequiredScopes []string
+	// TokenParams are added to the body of refresh requests, overriding the
+	// default parameters with the same name.
+	TokenParams url.Values
+	// RefreshBefore is how long before ExpiresAt the token is considered
+	// expired and gets refreshed. It defaults to 60 seconds when zero.
+	RefreshBefore time.Duration
+	// HTTPClient is used for token requests. It defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// TokenTimeout bounds each token refresh request. It defaults to
+	// DefaultTokenTimeout; a negative value disables the timeout.
+	TokenTimeout time.Duration
+
+	// OnTokenRefreshed, if set, is called after each successful token
+	// refresh with a copy of the updated credentials so they can be
+	// persisted. The refresh token may have been rotated by the server.
+	OnTokenRefreshed func(auth OneDriveAuth)
+	// UserAgent is sent with token requests. It defaults to
+	// DefaultUserAgent.
+	UserAgent string
+	// UseServerDate dates refreshed tokens by the Date header of the token
+	// response when it is earlier than the local clock, by at most 5
+	// minutes, so that a local clock running ahead does not keep expired
+	// tokens in use.
+	UseServerDate bool
+	// Stats, if set, counts token refreshes.
+	Stats *Stats
+	// Logger, if set, is notified of token requests. Request bodies,
+	// which hold the credentials, are not logged.
+	Logger Logger
+
+	// now is the clock expiry is judged by. It defaults to time.Now and is
+	// replaced in tests.
+	now func() time.Time
+
+	// mu holds the *sync.RWMutex guarding the token. atomic.Pointer would
+	// make the struct uncopyable, and auths are passed by value.
+	mu              atomic.Value
+	stopAutoRefresh context.CancelFunc
+}
+
+// locker returns the lock in d.mu, creating it on first use so that auths
+// built as struct literals are safe to share between goroutines. Once
+// created it costs a single atomic load.
+func (d *OneDriveAuth) locker() *sync.RWMutex {
+	if mu, ok := d.mu.Load().(*sync.RWMutex); ok {
+		return mu
+	}
+	d.mu.CompareAndSwap(nil, new(sync.RWMutex))
+	return d.mu.Load().(*sync.RWMutex)
+}
+
+// invalidateToken marks the token as expired so that the next ValidToken
+// refreshes it. It does nothing if the token has already been replaced.
+func (d *OneDriveAuth) invalidateToken(token string) {
+	mu := d.locker()
+	mu.Lock()
+	defer mu.Unlock()
+
+	if d.AccessToken == token {
+		d.ExpiresAt = time.Time{}
+	}
+}
+
+func (d *OneDriveAuth) expired() bool {
+	return d.expiresWithin(d.refreshBefore())
+}
+
+// expiresWithin reports whether the token expires within the duration.
+func (d *OneDriveAuth) expiresWithin(within time.Duration) bool {
+	if d.ExpiresAt.IsZero() {
+		return true
+	}
+	return !d.timeNow().Add(within).Before(d.ExpiresAt)
+}
+
+func (d *OneDriveAuth) timeNow() time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+	return time.Now()
+}
+
+func (d *OneDriveAuth) refreshBefore() time.Duration {
+	if d.RefreshBefore == 0 {
+		return defaultRefreshBefore
+	}
+	return d.RefreshBefore
+}
+
+// ValidToken returns the current access token, refreshing it first if it
+// has expired. It is safe for concurrent use; concurrent callers that
+// observe an expired token wait for a single refresh instead of each
+// issuing their own.
+func (d *OneDriveAuth) ValidToken() (token string, err error) {
+	return d.ValidTokenContext(context.Background())
+}
+
+func (d *OneDriveAuth) ValidTokenContext(ctx context.Context) (token string, err error) {
+	token, _, err = d.validToken(ctx)
+	return
+}
+
+// TimeUntilExpiry returns how long the current access token is valid for.
+// It is negative once the token has expired. RefreshBefore is not taken
+// into account.
+func (d *OneDriveAuth) TimeUntilExpiry() time.Duration {
+	mu := d.locker()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return d.ExpiresAt.Sub(d.timeNow())
+}
+
+// Expired reports whether the current access token has expired. A token
+// without ExpiresAt is expired.
+func (d *OneDriveAuth) Expired() bool {
+	mu := d.locker()
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return d.expiresWithin(0)
+}
+
+// validToken is like ValidTokenContext and reports whether this call
+// refreshed the token.
+func (d *OneDriveAuth) validToken(ctx context.Context) (token string, refreshed bool, err error) {
+	mu := d.locker()
+
+	mu.RLock()
+	if !d.expired() {
+		token = d.AccessToken
+		err = d.checkScopes()
+		mu.RUnlock()
+		if err != nil {
+			token = ""
+		}
+		return
+	}
+	mu.RUnlock()
+
+	if token, refreshed, err = d.refreshIf(ctx, d.expired); err != nil {
+		return
+	}
+	mu.RLock()
+	err = d.checkScopes()
+	mu.RUnlock()
+	if err != nil {
+		token = ""
+	}
+	return
+}
+
+// checkScopes returns a *ScopeError if GrantedScopes are known and lack
+// any of RequiredScopes. It must be called with the lock held.
+func (d *OneDriveAuth) checkScopes() error {
+	if len(d.RequiredScopes) == 0 || len(d.GrantedScopes) == 0 {
+		return nil
+	}
+
+	granted := make(map[string]bool, len(d.GrantedScopes))
+	for _, scope := range d.GrantedScopes {
+		granted[scopeName(scope)] = true
+	}
+	var missing []string
+	for _, scope := range d.RequiredScopes {
+		if !granted[scopeName(scope)] {
+			missing = append(missing, scope)
+		}
+	}
+	if len(missing) > 0 {
+		return &ScopeError{Missing: missing}
+	}
+	return nil
+}
+
+// scopeName strips the resource prefix from a scope and lowercases it.
+func scopeName(scope string) string {
+	if i := strings.LastIndex(scope, "/"); i >= 0 {
+		scope = scope[i+1:]
+	}
+	return strings.ToLower(scope)
+}
+
+// refreshIf refreshes the token if stale, which is called with the write
+// lock held, reports true, and returns the current token.
+func (d *OneDriveAuth) refreshIf(ctx context.Context, stale func() bool) (token string, refreshed bool, err error) {
+	mu := d.locker()
+
+	mu.Lock()
+	if stale() {
+		if err = d.refresh(ctx); err != nil {
+			mu.Unlock()
+			return
+		}
+		refreshed = true
+	}
+	token = d.AccessToken
+	snapshot := *d
+	snapshot.mu = atomic.Value{}
+	snapshot.stopAutoRefresh = nil
+	mu.Unlock()
+
+	if refreshed && d.OnTokenRefreshed != nil {
+		d.OnTokenRefreshed(snapshot)
+	}
+	return
+}
+
+// refresh must be called with the write lock held.
+func (d *OneDriveAuth) refresh(ctx context.Context) (err error) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("client_id", d.ClientId)
+	data.Set("client_secret", d.ClientSecret)
+	data.Set("redirect_uri", d.RedirectUri)
+	data.Set("refresh_token", d.RefreshToken)
+	if len(d.Scopes) > 0 {
+		data.Set("scope", strings.Join(d.Scopes, " "))
+	}
+	for key, values := range d.TokenParams {
+		data[key] = values
+	}
+
+	timeout := d.TokenTimeout
+	if timeout == 0 {
+		timeout = DefaultTokenTimeout
+	}
+
+	tc := tokenClient{
+		client:     d.HTTPClient,
+		logger:     d.Logger,
+		userAgent:  d.UserAgent,
+		timeout:    timeout,
+		serverDate: d.UseServerDate,
+		now:        d.now,
+	}
+	d.Stats.countTokenRefresh()
+	respVal, issuedAt, err := tc.request(ctx, d.tokenURL(), data)
+	if err != nil {
+		return
+	}
+
+	d.AccessToken = respVal.AccessToken
+	if respVal.RefreshToken != "" {
+		d.RefreshToken = respVal.RefreshToken
+	}
+	d.ExpiresAt = issuedAt.Add(time.Duration(respVal.ExpiresIn) * time.Second)
+	if respVal.Scope != "" {
+		d.GrantedScopes = strings.Fields(respVal.Scope)
+	}
+	return
+}
+
+func (d *OneDriveAuth) tokenURL() string {
+	if d.TokenURL != "" {
+		return d.TokenURL
+	}
+	return tokenUrl
+}
+
+// tokenClient sends token requests.
+type tokenClient struct {
+	client     *http.Client
+	logger     Logger
+	userAgent  string
+	timeout    time.Duration
+	serverDate bool
+	now        func() time.Time
+}
+
+// request posts data to the token endpoint. issuedAt is the local time the
+// token lifetime counts from: when the request was sent or, with
+// serverDate, the time of the response's Date header if that is earlier.
+func (c tokenClient) request(ctx context.Context, endpoint string, data url.Values) (respVal RefreshResp, issuedAt time.Time, err error) {
+	client, logger, ua := c.client, c.logger, c.userAgent
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(data.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent(ua))
+
+	var entry RequestLog
+	if logger != nil {
+		entry = RequestLog{Method: req.Method, URL: endpoint, Header: redactHeader(req.Header)}
+		logger.BeforeRequest(entry)
+	}
+	start := time.Now()
+	issuedAt = start
+	if c.now != nil {
+		issuedAt = c.now()
+	}
+	resp, err := client.Do(req)
+	if logger != nil {
+		entry.Duration = time.Since(start)
+		entry.Err = err
+		if resp != nil {
+			entry.Status = resp.StatusCode
+		}
+		logger.AfterRequest(entry)
+	}
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var buf []byte
+	if buf, err = ioutil.ReadAll(resp.Body); err != nil {
+		return
+	}
+
+	if c.serverDate {
+		issuedAt = serverIssuedAt(issuedAt, resp.Header)
+	}
+
+	if resp.StatusCode != 200 {
+		tokenErr := &TokenError{StatusCode: resp.StatusCode, Status: resp.Status}
+		// the body is best-effort; keep the status if it is not JSON
+		json.Unmarshal(buf, tokenErr)
+		err = tokenErr
+		return
+	}
+
+	if err = json.Unmarshal(buf, &respVal); err != nil {
+		return
+	}
+
+	if respVal.AccessToken == "" {
+		err = fmt.Errorf("Token response from %s has no access_token", endpoint)
+		return
+	}
+	if !respVal.hasExpiresIn {
+		respVal.ExpiresIn = int64(defaultTokenLifetime / time.Second)
+	} else if respVal.ExpiresIn <= 0 {
+		err = fmt.Errorf("Token response from %s has invalid expires_in %d", endpoint, respVal.ExpiresIn)
+	}
+	return
+}
+
+// maxClockCorrection bounds how much earlier than the local clock the Date
+// header may date a token, so that a badly skewed clock does not make every
+// token look expired.
+const maxClockCorrection = 5 * time.Minute
+
+// serverIssuedAt returns the Date of the response if it is before the
+// local time sent, bounded by maxClockCorrection, and sent otherwise.
+func serverIssuedAt(sent time.Time, h http.Header) time.Time {
+	date, err := http.ParseTime(h.Get("Date"))
+	if err != nil || !date.Before(sent) {
+		return sent
+	}
+	if earliest := sent.Add(-maxClockCorrection); date.Before(earliest) {
+		return earliest
+	}
+	return date
+}
+
+func userAgent(ua string) string {
+	if ua == "" {
+		return DefaultUserAgent
+	}
+	return ua
+}
+
+// ExchangeCode redeems an OAuth authorization code obtained from the
+// consent page (see AuthorizeURL) for an access and refresh token.
+func ExchangeCode(clientId, clientSecret, redirectUri, code string) (auth OneDriveAuth, err error) {
+	return ExchangeCodeContext(context.Background(), clientId, clientSecret, redirectUri, code)
+}
+
+func ExchangeCodeContext(ctx context.Context, clientId, clientSecret, redirectUri, code string) (auth OneDriveAuth, err error) {
+	return ExchangeCodeWithBackendContext(ctx, BackendLive, clientId, clientSecret, redirectUri, code)
+}
+
+func ExchangeCodeWithBackend(backend Backend, clientId, clientSecret, redirectUri, code string) (auth OneDriveAuth, err error) {
+	return ExchangeCodeWithBackendContext(context.Background(), backend, clientId, clientSecret, redirectUri, code)
+}
+
+// ExchangeCodeWithBackendContext is like ExchangeCodeContext but redeems a
+// code obtained from AuthorizeURLWithBackend. For BackendGraph the code is
+// redeemed at GraphTokenURL with DefaultGraphScopes, which the returned
+// auth keeps for refreshing.
+func ExchangeCodeWithBackendContext(ctx context.Context, backend Backend, clientId, clientSecret, redirectUri, code string) (auth OneDriveAuth, err error) {
+	data := url.Values{}
+	data.Set("grant_type", "authorization_code")
+	data.Set("client_id", clientId)
+	data.Set("client_secret", clientSecret)
+	data.Set("redirect_uri", redirectUri)
+	data.Set("code", code)
+
+	endpoint := tokenUrl
+	if backend == BackendGraph {
+		endpoint = GraphTokenURL
+		data.Set("scope", strings.Join(DefaultGraphScopes, " "))
+	}
+
+	respVal, issuedAt, err := tokenClient{timeout: DefaultTokenTimeout}.request(ctx, endpoint, data)
+	if err != nil {
+		return
+	}
+
+	auth = OneDriveAuth{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		RedirectUri:  redirectUri,
+		AccessToken:  respVal.AccessToken,
+		RefreshToken: respVal.RefreshToken,
+		ExpiresAt:    issuedAt.Add(time.Duration(respVal.ExpiresIn) * time.Second),
+	}
+	if backend == BackendGraph {
+		auth.TokenURL = GraphTokenURL
+		auth.Scopes = DefaultGraphScopes
+	}
+	if respVal.Scope != "" {
+		auth.GrantedScopes = strings.Fields(respVal.Scope)
+	}
+	return
+}
+
+// AuthorizeURL builds the consent page URL the user has to visit to grant
+// access. After consent the browser is redirected to redirectUri with a
+// code query parameter that can be passed to ExchangeCode.
+func AuthorizeURL(clientId, redirectUri string, scopes []string) string {
+	return AuthorizeURLWithBackend(BackendLive, clientId, redirectUri, scopes)
+}
+
+// AuthorizeURLWithBackend is like AuthorizeURL but builds the consent page
+// URL of the backend's identity platform: GraphAuthorizeURL for
+// BackendGraph. Pass the code to ExchangeCodeWithBackend.
+func AuthorizeURLWithBackend(backend Backend, clientId, redirectUri string, scopes []string) string {
+	endpoint := authorizeUrl
+	if backend == BackendGraph {
+		endpoint = GraphAuthorizeURL
+	}
+	params := url.Values{}
+	params.Set("client_id", clientId)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("response_type", "code")
+	params.Set("redirect_uri", redirectUri)
+	return endpoint + "?" + params.Encode()
+}
@@ -0,0 +1,83 @@
+package onedriveclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAuthLockerConcurrent(t *testing.T) {
+	auth := &OneDriveAuth{}
+
+	var wg sync.WaitGroup
+	mus := make([]*sync.RWMutex, 8)
+	for i := range mus {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			mus[i] = auth.locker()
+		}(i)
+	}
+	wg.Wait()
+
+	for i, mu := range mus {
+		if mu == nil || mu != mus[0] {
+			t.Fatalf("locker() %d returned %p, want %p", i, mu, mus[0])
+		}
+	}
+}
+
+func TestValidTokenRefreshBoundary(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		skew      time.Duration
+		expiresIn time.Duration
+		refresh   bool
+	}{
+		{"one second from expiry", 5 * time.Minute, time.Second, true},
+		{"just inside the skew", 5 * time.Minute, 5*time.Minute - time.Second, true},
+		{"just outside the skew", 5 * time.Minute, 5*time.Minute + time.Second, false},
+		{"just inside the default skew", 0, defaultRefreshBefore - time.Second, true},
+		{"just outside the default skew", 0, defaultRefreshBefore + time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refreshes := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				refreshes++
+				fmt.Fprint(w, `{"access_token":"new","refresh_token":"refresh","expires_in":3600}`)
+			}))
+			defer srv.Close()
+
+			auth := &OneDriveAuth{
+				AccessToken:   "old",
+				RefreshToken:  "refresh",
+				ExpiresAt:     now.Add(tt.expiresIn),
+				TokenURL:      srv.URL,
+				RefreshBefore: tt.skew,
+				now:           func() time.Time { return now },
+			}
+			token, err := auth.ValidToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want, wantRefreshes, wantExpiry := "old", 0, now.Add(tt.expiresIn)
+			if tt.refresh {
+				want, wantRefreshes, wantExpiry = "new", 1, now.Add(time.Hour)
+			}
+			if token != want || refreshes != wantRefreshes {
+				t.Errorf("got token %s after %d refreshes, want %s after %d", token, refreshes, want, wantRefreshes)
+			}
+			if !auth.ExpiresAt.Equal(wantExpiry) {
+				t.Errorf("ExpiresAt = %v, want %v", auth.ExpiresAt, wantExpiry)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package onedriveclient
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// autoRefreshRetry is the delay before a failed background refresh is
+// retried and the minimum delay between background refreshes.
+const autoRefreshRetry = 10 * time.Second
+
+// StartAutoRefresh starts a goroutine that refreshes the token ahead of
+// time, RefreshBefore before it would be refreshed on demand, so that
+// requests do not wait for refreshes. It runs until ctx is done or
+// StopAutoRefresh is called. Starting it again stops the previous one.
+// Failed refreshes are retried unless the refresh token was rejected;
+// ValidToken will then report the error.
+func (d *OneDriveAuth) StartAutoRefresh(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	mu := d.locker()
+	mu.Lock()
+	if d.stopAutoRefresh != nil {
+		d.stopAutoRefresh()
+	}
+	d.stopAutoRefresh = cancel
+	mu.Unlock()
+
+	go d.autoRefresh(ctx)
+}
+
+// StopAutoRefresh stops the goroutine started by StartAutoRefresh.
+func (d *OneDriveAuth) StopAutoRefresh() {
+	mu := d.locker()
+	mu.Lock()
+	defer mu.Unlock()
+
+	if d.stopAutoRefresh != nil {
+		d.stopAutoRefresh()
+		d.stopAutoRefresh = nil
+	}
+}
+
+func (d *OneDriveAuth) autoRefresh(ctx context.Context) {
+	mu := d.locker()
+	var wait time.Duration
+
+	for {
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+
+		// refresh within twice the on-demand window
+		early := func() bool { return d.expiresWithin(2 * d.refreshBefore()) }
+		if _, _, err := d.refreshIf(ctx, early); err != nil {
+			if errors.Is(err, ErrRefreshTokenInvalid) {
+				return
+			}
+			wait = autoRefreshRetry
+			continue
+		}
+
+		mu.RLock()
+		wait = d.ExpiresAt.Sub(d.timeNow()) - 2*d.refreshBefore()
+		mu.RUnlock()
+		if wait < autoRefreshRetry {
+			// tokens that live shorter than the window
+			wait = autoRefreshRetry
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package onedriveclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const DefaultConcurrency = 8
+
+// BatchError collects the errors of a batch operation keyed by the id or
+// path they belong to. errors.Is and errors.As look at every error.
+type BatchError struct {
+	Errors map[string]error
+}
+
+func (e *BatchError) Error() string {
+	keys := make([]string, 0, len(e.Errors))
+	for key := range e.Errors {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	msgs := make([]string, len(keys))
+	for i, key := range keys {
+		msgs[i] = fmt.Sprintf("%s: %s", key, e.Errors[key])
+	}
+	return fmt.Sprintf("%d errors: %s", len(keys), strings.Join(msgs, "; "))
+}
+
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func (d *OneDrive) concurrency() int {
+	if d.Concurrency > 0 {
+		return d.Concurrency
+	}
+	return DefaultConcurrency
+}
+
+// forEach calls fn for every index below n using at most d.Concurrency
+// goroutines. Once ctx is done no further calls are started.
+func (d *OneDrive) forEach(ctx context.Context, n int, fn func(i int)) {
+	sem := make(chan struct{}, d.concurrency())
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func (d *OneDrive) NodeInfos(ids []string) (infos []NodeInfo, err error) {
+	return d.NodeInfosContext(context.Background(), ids)
+}
+
+// NodeInfosContext fetches the info of all ids concurrently, using at most
+// d.Concurrency requests at a time. infos is in the same order as ids. If
+// some lookups fail, their entries are left empty and err is a *BatchError
+// keyed by id.
+func (d *OneDrive) NodeInfosContext(ctx context.Context, ids []string) (infos []NodeInfo, err error) {
+	infos = make([]NodeInfo, len(ids))
+	errs := make([]error, len(ids))
+
+	d.forEach(ctx, len(ids), func(i int) {
+		infos[i], errs[i] = d.NodeInfoContext(ctx, ids[i])
+	})
+
+	batchErr := &BatchError{Errors: make(map[string]error)}
+	for i, id := range ids {
+		if errs[i] == nil && ctx.Err() != nil && infos[i].Id == "" {
+			errs[i] = ctx.Err()
+		}
+		if errs[i] != nil {
+			batchErr.Errors[id] = errs[i]
+		}
+	}
+	if len(batchErr.Errors) > 0 {
+		err = batchErr
+	}
+	return
+}
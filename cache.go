@@ -0,0 +1,138 @@
+package onedriveclient
+
+import (
+	"sync"
+	"time"
+)
+
+// PathCache remembers which child id a name resolves to inside a parent so
+// that ResolvePath does not have to list every folder along the path on
+// every call. Set OneDrive.PathCache to enable it.
+//
+// Entries are dropped automatically after TTL and whenever the client
+// deletes, renames or moves the node. Changes made by other clients are
+// only picked up once the entry expires; call Invalidate or Clear to force
+// it sooner.
+type PathCache struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[pathCacheKey]pathCacheEntry
+}
+
+type pathCacheKey struct {
+	parentId string
+	name     string
+}
+
+type pathCacheEntry struct {
+	id      string
+	expires time.Time
+}
+
+func NewPathCache(ttl time.Duration) *PathCache {
+	return &PathCache{
+		TTL:     ttl,
+		entries: make(map[pathCacheKey]pathCacheEntry),
+	}
+}
+
+func (c *PathCache) key(parentId string, name string) pathCacheKey {
+	return pathCacheKey{parentId, NormalizeName(name)}
+}
+
+func (c *PathCache) Get(parentId string, name string) (id string, ok bool) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.key(parentId, name)
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return "", false
+	}
+	return entry.id, true
+}
+
+func (c *PathCache) Put(parentId string, name string, id string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[pathCacheKey]pathCacheEntry)
+	}
+	c.entries[c.key(parentId, name)] = pathCacheEntry{id, time.Now().Add(c.TTL)}
+}
+
+// Invalidate drops every entry that resolves to id.
+func (c *PathCache) Invalidate(id string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if entry.id == id {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func (c *PathCache) Clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[pathCacheKey]pathCacheEntry)
+}
+
+// ancestorCache remembers the info of folders looked up by FullPath.
+type ancestorCache struct {
+	mu    sync.Mutex
+	nodes map[string]NodeInfo
+}
+
+func (c *ancestorCache) get(id string) (info NodeInfo, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info, ok = c.nodes[id]
+	return
+}
+
+func (c *ancestorCache) put(info NodeInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.nodes == nil {
+		c.nodes = make(map[string]NodeInfo)
+	}
+	c.nodes[info.Id] = info
+}
+
+func (c *ancestorCache) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.nodes, id)
+}
+
+// invalidate forgets everything cached about the node after it has been
+// deleted, renamed or moved.
+func (d *OneDrive) invalidate(id string) {
+	d.PathCache.Invalidate(id)
+	d.ancestors.invalidate(id)
+}
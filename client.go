@@ -0,0 +1,39 @@
+package onedriveclient
+
+import (
+	"context"
+	"github.com/koofr/go-ioutils"
+	"io"
+)
+
+// Client is the core method set of OneDrive. Code that depends on Client
+// instead of *OneDrive can be tested against the in-memory implementation
+// in package onedrivefake.
+type Client interface {
+	NodeInfo(id string) (info NodeInfo, err error)
+	NodeInfoContext(ctx context.Context, id string) (info NodeInfo, err error)
+	RootInfo() (info NodeInfo, err error)
+	RootInfoContext(ctx context.Context) (info NodeInfo, err error)
+	NodeFiles(id string) (files []NodeInfo, err error)
+	NodeFilesContext(ctx context.Context, id string) (files []NodeInfo, err error)
+	ResolvePath(pth string) (id string, err error)
+	ResolvePathContext(ctx context.Context, pth string) (id string, err error)
+
+	Download(id string, span *ioutils.FileSpan) (info NodeInfo, content io.ReadCloser, err error)
+	DownloadContext(ctx context.Context, id string, span *ioutils.FileSpan) (info NodeInfo, content io.ReadCloser, err error)
+	Upload(dirId string, name string, content io.Reader) (err error)
+	UploadContext(ctx context.Context, dirId string, name string, content io.Reader) (err error)
+	UploadOverwrite(dirId string, name string, overwrite bool, content io.Reader) (newName string, err error)
+	UploadOverwriteContext(ctx context.Context, dirId string, name string, overwrite bool, content io.Reader) (newName string, err error)
+
+	CreateFolder(parentId string, name string) (info NodeInfo, err error)
+	CreateFolderContext(ctx context.Context, parentId string, name string) (info NodeInfo, err error)
+	Rename(id string, newName string) (info NodeInfo, err error)
+	RenameContext(ctx context.Context, id string, newName string) (info NodeInfo, err error)
+	Move(id string, newParentId string) (info NodeInfo, err error)
+	MoveContext(ctx context.Context, id string, newParentId string) (info NodeInfo, err error)
+	Delete(id string) (err error)
+	DeleteContext(ctx context.Context, id string) (err error)
+}
+
+var _ Client = (*OneDrive)(nil)
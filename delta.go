@@ -0,0 +1,112 @@
+package onedriveclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/koofr/go-httpclient"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrDeltaUnavailable is matched by errors of Delta with BackendLive, which
+// cannot track changes.
+var ErrDeltaUnavailable = errors.New("Delta unavailable")
+
+// ErrResyncRequired is matched by errors of Delta when the server no longer
+// accepts the token. Start over with an empty token and compare the
+// result, which lists every node, with the local state.
+var ErrResyncRequired = errors.New("Resync required")
+
+// DeltaChange is a node that changed since the delta token was issued.
+type DeltaChange struct {
+	// Node is the current info of the node. For deleted nodes only Id and
+	// ParentId are reliable.
+	Node    NodeInfo
+	Deleted bool
+}
+
+func (d *OneDrive) Delta(deltaToken string) (changes []DeltaChange, nextToken string, err error) {
+	return d.DeltaContext(context.Background(), deltaToken)
+}
+
+// DeltaContext returns the nodes of the drive that changed since deltaToken
+// was returned by a previous call, and the token to pass next time. An
+// empty deltaToken returns every node. All pages of the delta are fetched.
+// Only BackendGraph supports it.
+func (d *OneDrive) DeltaContext(ctx context.Context, deltaToken string) (changes []DeltaChange, nextToken string, err error) {
+	defer wrapOp(&err, "Delta", deltaToken)
+	if !d.graph() {
+		err = fmt.Errorf("%w: not supported by the Live API", ErrDeltaUnavailable)
+		return
+	}
+
+	pth, params, fullUrl := d.itemPath(graphRootId)+"/delta", url.Values{}, ""
+	switch {
+	case strings.HasPrefix(deltaToken, "https://"):
+		fullUrl = deltaToken
+	case deltaToken != "":
+		params.Set("token", deltaToken)
+	}
+
+	for {
+		var page graphPage
+		if page, err = d.deltaPage(ctx, pth, params, fullUrl); err != nil {
+			if isStatus(err, http.StatusGone) {
+				err = fmt.Errorf("%w: %w", ErrResyncRequired, err)
+			}
+			return nil, "", err
+		}
+
+		for i := range page.Value {
+			item := &page.Value[i]
+			change := DeltaChange{Node: item.nodeInfo(), Deleted: item.Deleted != nil}
+			changes = append(changes, change)
+		}
+
+		if page.DeltaLink != "" {
+			nextToken = deltaLinkToken(page.DeltaLink)
+			return
+		}
+		if page.NextLink == "" {
+			err = fmt.Errorf("Delta page has neither a next nor a delta link")
+			return
+		}
+		pth, params, fullUrl = "", nil, page.NextLink
+	}
+}
+
+func (d *OneDrive) deltaPage(ctx context.Context, pth string, params url.Values, fullUrl string) (page graphPage, err error) {
+	header, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         "GET",
+		Path:           pth,
+		Params:         params,
+		FullURL:        fullUrl,
+		Headers:        header,
+		ExpectedStatus: []int{200},
+		RespEncoding:   httpclient.EncodingJSON,
+		RespValue:      &page,
+	}
+	_, err = d.request(ctx, d.ApiClient, req)
+	return
+}
+
+// deltaLinkToken returns the token of a delta link, or the link itself if it
+// has none.
+func deltaLinkToken(deltaLink string) string {
+	u, err := url.Parse(deltaLink)
+	if err != nil {
+		return deltaLink
+	}
+	if token := u.Query().Get("token"); token != "" {
+		return token
+	}
+	return deltaLink
+}
@@ -0,0 +1,204 @@
+package onedriveclient
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/koofr/go-ioutils"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (d *OneDrive) DownloadURL(id string) (url string, err error) {
+	return d.DownloadURLContext(context.Background(), id)
+}
+
+// DownloadURLContext returns the URL the content of the node can be
+// downloaded from without fetching it. The URL is pre-authenticated, so
+// anyone holding it can download the file, and short-lived: it typically
+// expires within an hour and should be fetched right before use rather
+// than stored.
+func (d *OneDrive) DownloadURLContext(ctx context.Context, id string) (url string, err error) {
+	defer wrapOp(&err, "DownloadURL", id)
+	info, err := d.NodeInfoContext(ctx, id)
+	if err != nil {
+		return
+	}
+
+	if url = info.Source; url == "" {
+		err = fmt.Errorf("Cannot download %s", id)
+	}
+	return
+}
+
+func (d *OneDrive) DownloadResume(id string, from int64) (info NodeInfo, content io.ReadCloser, err error) {
+	return d.DownloadResumeContext(context.Background(), id, from, nil)
+}
+
+// DownloadResumeContext downloads the content of the node starting at byte
+// offset from, e.g. to continue an interrupted download. If the server
+// ignores the Range header and sends the whole content, the first from bytes
+// are discarded so that content always starts at from. info.Size is the size
+// of the whole file. If progress is set it is called with the absolute
+// offset as content is read.
+func (d *OneDrive) DownloadResumeContext(ctx context.Context, id string, from int64, progress ProgressFunc) (info NodeInfo, content io.ReadCloser, err error) {
+	defer wrapOp(&err, "DownloadResume", id)
+	info, err = d.NodeInfoContext(ctx, id)
+	if err != nil {
+		return
+	}
+
+	if from < 0 || from > info.Size {
+		err = fmt.Errorf("Cannot resume %s at %d, size is %d", id, from, info.Size)
+		return
+	}
+
+	var rng string
+	if from > 0 {
+		rng = fmt.Sprintf("bytes=%d-", from)
+	}
+
+	res, err := d.downloadSource(ctx, info, rng)
+	if err != nil {
+		return
+	}
+
+	body := newContextReadCloser(ctx, res.Body)
+
+	if from > 0 && res.StatusCode == http.StatusOK {
+		if _, err = io.CopyN(ioutil.Discard, body, from); err != nil {
+			body.Close()
+			err = fmt.Errorf("Cannot skip to %d of %s: %w", from, id, err)
+			return
+		}
+	}
+
+	var r io.Reader = body
+	if progress != nil {
+		r = &progressReader{r: body, n: from, total: info.Size, progress: progress}
+	}
+
+	content = readCloser{r, body}
+	return
+}
+
+func (d *OneDrive) DownloadTo(id string, w io.Writer, span *ioutils.FileSpan, progress ProgressFunc) (n int64, err error) {
+	return d.DownloadToContext(context.Background(), id, w, span, progress)
+}
+
+// DownloadToContext writes the content of the node, or of span if it is
+// not nil, to w and returns the number of bytes written. It fails if fewer
+// or more bytes than expected arrive; the length is only unknown if the
+// server sends the whole content without a Content-Length. If progress is set it is called with
+// the bytes written so far and the expected total.
+func (d *OneDrive) DownloadToContext(ctx context.Context, id string, w io.Writer, span *ioutils.FileSpan, progress ProgressFunc) (n int64, err error) {
+	defer wrapOp(&err, "DownloadTo", id)
+	info, content, err := d.DownloadContext(ctx, id, span)
+	if err != nil {
+		return
+	}
+	defer content.Close()
+
+	expected := info.Size
+	if span != nil {
+		expected = span.End - span.Start + 1
+	}
+
+	var r io.Reader = content
+	if progress != nil {
+		r = &progressReader{r: content, total: expected, progress: progress}
+	}
+
+	if expected >= 0 {
+		// read one byte more than expected to detect overlong responses
+		r = io.LimitReader(r, expected+1)
+	}
+	if n, err = io.Copy(w, r); err != nil {
+		return
+	}
+	if expected >= 0 && n != expected {
+		err = fmt.Errorf("Download of %s has %d bytes, expected %d", id, n, expected)
+	}
+	return
+}
+
+// ErrHashMismatch is returned by the content of DownloadVerified when the
+// downloaded bytes do not match the hash reported by the server.
+var ErrHashMismatch = errors.New("Hash mismatch")
+
+func (d *OneDrive) DownloadVerified(id string) (info NodeInfo, content io.ReadCloser, err error) {
+	return d.DownloadVerifiedContext(context.Background(), id)
+}
+
+// DownloadVerifiedContext downloads the whole content of the node and
+// hashes it while it is read. Once the content is read to the end, a final
+// Read returns an error matching ErrHashMismatch instead of io.EOF if the
+// hash does not match the one in info.Hashes. SHA-1 is preferred over
+// QuickXorHash. If the server reports no hash for the file verification is
+// skipped.
+func (d *OneDrive) DownloadVerifiedContext(ctx context.Context, id string) (info NodeInfo, content io.ReadCloser, err error) {
+	defer wrapOp(&err, "DownloadVerified", id)
+	info, content, err = d.DownloadContext(ctx, id, nil)
+	if err != nil {
+		return
+	}
+
+	h, expected, sum := hashes(info.Hashes)
+	if h == nil {
+		return
+	}
+
+	content = &verifyingReader{
+		ReadCloser: content,
+		id:         id,
+		h:          h,
+		expected:   expected,
+		sum:        sum,
+	}
+	return
+}
+
+// hashes picks the hash to verify content against, preferring SHA-1. h is
+// nil if the server reported no hash.
+func hashes(hs Hashes) (h hash.Hash, expected string, sum func(hash.Hash) string) {
+	switch {
+	case hs.Sha1 != "":
+		return sha1.New(), strings.ToLower(hs.Sha1), hexString
+	case hs.QuickXor != "":
+		return newQuickXorHash(), hs.QuickXor, quickXorString
+	}
+	return
+}
+
+func hexString(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type verifyingReader struct {
+	io.ReadCloser
+	id       string
+	h        hash.Hash
+	expected string
+	sum      func(hash.Hash) string
+}
+
+func (r *verifyingReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	r.h.Write(p[:n])
+	if err == io.EOF {
+		if actual := r.sum(r.h); actual != r.expected {
+			err = fmt.Errorf("%w for %s: expected %s, got %s", ErrHashMismatch, r.id, r.expected, actual)
+		}
+	}
+	return
+}
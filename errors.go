@@ -0,0 +1,229 @@
+package onedriveclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/koofr/go-httpclient"
+	"net/http"
+	"strings"
+)
+
+// ErrNotFound is matched (via errors.Is) by errors caused by a missing node.
+var ErrNotFound = errors.New("Not found")
+
+// ErrShortcut is matched by errors of path lookups that run into a shortcut
+// while OneDrive.FollowShortcuts is not set.
+var ErrShortcut = errors.New("Shortcut")
+
+// ErrPreconditionFailed is matched by errors of conditional requests that
+// were rejected because the node's ETag no longer matches.
+var ErrPreconditionFailed = errors.New("Precondition failed")
+
+// ErrNotModified is matched by the error of DownloadIfChanged when the node
+// still has the given ETag.
+var ErrNotModified = errors.New("Not modified")
+
+// ErrUploadSessionExpired is matched by errors of ResumeUploadSession when
+// the session has expired or was canceled. The upload has to be started
+// over with a new session.
+var ErrUploadSessionExpired = errors.New("Upload session expired")
+
+// NodeNotFoundError is returned when a node does not exist. Id is set when
+// the node was looked up by id, Path when it was looked up by path. It
+// matches ErrNotFound.
+type NodeNotFoundError struct {
+	Id   string
+	Path string
+}
+
+func (e *NodeNotFoundError) Error() string {
+	if e.Path != "" {
+		return "Not found " + e.Path
+	}
+	return "Not found " + e.Id
+}
+
+func (e *NodeNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// IsNotFound reports whether err is caused by a missing node.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// ErrNotFolder is matched (via errors.Is) by errors caused by a path
+// component that is expected to be a folder but is a file.
+var ErrNotFolder = errors.New("Not a folder")
+
+// ErrAmbiguous is matched (via errors.Is) when a case-sensitive path lookup
+// finds no exact match but several names that differ only by case.
+var ErrAmbiguous = errors.New("Ambiguous name")
+
+// ErrConflict is matched (via errors.Is) by errors caused by a node with
+// the same name already existing at the destination.
+var ErrConflict = errors.New("Conflict")
+
+// ErrRefreshTokenInvalid is matched (via errors.Is) by token errors caused
+// by a revoked or expired refresh token. The user has to re-authenticate.
+var ErrRefreshTokenInvalid = errors.New("Refresh token is invalid")
+
+// ScopeError is returned by ValidToken when the access token lacks scopes
+// listed in OneDriveAuth.RequiredScopes.
+type ScopeError struct {
+	Missing []string
+}
+
+func (e *ScopeError) Error() string {
+	return "Token lacks scopes " + strings.Join(e.Missing, " ")
+}
+
+// TokenError is returned when the token endpoint responds with a non-200
+// status.
+type TokenError struct {
+	StatusCode  int
+	Status      string
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *TokenError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("Token refresh failed %d: %s: %s", e.StatusCode, e.Code, e.Description)
+	}
+	return fmt.Sprintf("Token refresh failed %d: %s", e.StatusCode, e.Status)
+}
+
+func (e *TokenError) Is(target error) bool {
+	return target == ErrRefreshTokenInvalid && e.InvalidGrant()
+}
+
+// InvalidGrant reports whether the refresh token (or authorization code)
+// was rejected.
+func (e *TokenError) InvalidGrant() bool {
+	return e.StatusCode == 400 && e.Code == "invalid_grant"
+}
+
+// Temporary reports whether the request may succeed if retried.
+func (e *TokenError) Temporary() bool {
+	return !e.InvalidGrant() && (e.StatusCode == 429 || e.StatusCode >= 500)
+}
+
+// isConflict reports whether err is the API's response to a name clash.
+// The API answers either with 409 or with 400 and resource_already_exists.
+func isConflict(err error) bool {
+	ise, ok := invalidStatus(err)
+	if !ok {
+		return false
+	}
+	return ise.Got == http.StatusConflict ||
+		(ise.Got == http.StatusBadRequest && strings.Contains(ise.Content, "resource_already_exists"))
+}
+
+func isPreconditionFailed(err error) bool {
+	return isStatus(err, http.StatusPreconditionFailed)
+}
+
+// APIError is the error body the API sends with failed requests. It wraps
+// the *httpclient.InvalidStatusError of the response.
+type APIError struct {
+	StatusCode int
+	// Code is the API's error code, e.g. "resource_quota_exceeded" or
+	// "accessDenied".
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s (%d)", e.Code, e.Message, e.StatusCode)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// AsAPIError returns the API error body err carries, if any.
+func AsAPIError(err error) (apiErr *APIError, ok bool) {
+	ok = errors.As(err, &apiErr)
+	return
+}
+
+// parseAPIError wraps err in an *APIError if it is a failed response with
+// an error body.
+func parseAPIError(err error) error {
+	ise, ok := httpclient.IsInvalidStatusError(err)
+	if !ok {
+		return err
+	}
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(ise.Content), &body) != nil || body.Error.Code == "" {
+		return err
+	}
+	return &APIError{
+		StatusCode: ise.Got,
+		Code:       body.Error.Code,
+		Message:    body.Error.Message,
+		Err:        err,
+	}
+}
+
+// invalidStatus is like httpclient.IsInvalidStatusError but also looks
+// into wrapping errors such as *APIError and *OpError.
+func invalidStatus(err error) (ise *httpclient.InvalidStatusError, ok bool) {
+	// httpclient returns the error by value
+	var value httpclient.InvalidStatusError
+	if errors.As(err, &value) {
+		return &value, true
+	}
+	ok = errors.As(err, &ise)
+	return
+}
+
+func isStatus(err error, statusCode int) bool {
+	ise, ok := invalidStatus(err)
+	return ok && ise.Got == statusCode
+}
+
+// OpError is returned by the client's methods. It records the method and
+// the id or path it failed with and wraps the cause, so errors.Is and
+// errors.As see through it.
+type OpError struct {
+	Op  string
+	Arg string
+	Err error
+}
+
+func (e *OpError) Error() string {
+	return fmt.Sprintf("onedrive: %s %q: %s", e.Op, e.Arg, e.Err)
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// wrapOp wraps *err in an *OpError unless it is nil. An *OpError of a
+// method called internally is replaced, so that the error reports the
+// method the caller called. It is meant to be deferred.
+func wrapOp(err *error, op string, arg string) {
+	if *err == nil {
+		return
+	}
+	*err = &OpError{Op: op, Arg: arg, Err: opCause(*err)}
+}
+
+// opCause returns the cause of err if it is an *OpError and err otherwise.
+// Errors of internally called methods that get more context before they
+// are returned are unwrapped with it so that wrapOp does not nest them.
+func opCause(err error) error {
+	if opErr, ok := err.(*OpError); ok {
+		return opErr.Err
+	}
+	return err
+}
@@ -0,0 +1,324 @@
+package onedriveclient
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/koofr/go-httpclient"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Backend selects the API a OneDrive client talks to.
+type Backend int
+
+const (
+	// BackendLive is the legacy Live SDK API (apis.live.net/v5.0).
+	BackendLive Backend = iota
+	// BackendGraph is the Microsoft Graph API (graph.microsoft.com/v1.0).
+	BackendGraph
+)
+
+const (
+	liveBaseUrl  = "https://apis.live.net/v5.0"
+	graphBaseUrl = "https://graph.microsoft.com/v1.0"
+
+	liveRootId  = "me/skydrive"
+	graphRootId = "root"
+)
+
+// DefaultGraphScopes are requested on refresh by clients created for
+// BackendGraph unless OneDriveAuth.Scopes is set.
+var DefaultGraphScopes = []string{"Files.ReadWrite.All", "offline_access"}
+
+// NewOneDriveClientWithBackend is like NewOneDriveClient but talks to the
+// given backend. Node ids are not interchangeable between backends. For
+// BackendGraph, tokens are refreshed against GraphTokenURL with
+// DefaultGraphScopes unless auth says otherwise.
+func NewOneDriveClientWithBackend(auth OneDriveAuth, backend Backend) *OneDrive {
+	api := httpclient.New()
+	if backend == BackendGraph {
+		api.BaseURL, _ = url.Parse(graphBaseUrl)
+		if auth.TokenURL == "" {
+			auth.TokenURL = GraphTokenURL
+		}
+		if len(auth.Scopes) == 0 {
+			auth.Scopes = DefaultGraphScopes
+		}
+	}
+	d := NewOneDriveClientWithClients(auth, api, httpclient.New())
+	d.Backend = backend
+	if backend == BackendGraph {
+		// Graph provides upload sessions itself
+		d.SessionClient = api
+	}
+	return d
+}
+
+func (d *OneDrive) graph() bool {
+	return d.Backend == BackendGraph
+}
+
+func (d *OneDrive) rootId() string {
+	if d.graph() {
+		return graphRootId
+	}
+	return liveRootId
+}
+
+// itemPath returns the API path of the node.
+//
+// Paths built here and passed as RequestData.Path are escaped by httpclient,
+// so ids and names must be used verbatim. Escaping them again would send
+// "a%2520b" for "a b"; names with '%', '#' or '?' would not be found.
+func (d *OneDrive) itemPath(id string) string {
+	if !d.graph() {
+		return "/" + id
+	}
+	if id == graphRootId || id == "" {
+		return "/me/drive/root"
+	}
+	if driveId, itemId, ok := strings.Cut(id, "/"); ok {
+		return "/drives/" + driveId + "/items/" + itemId
+	}
+	return "/me/drive/items/" + id
+}
+
+// remoteId returns the id under which an item of another user's drive is
+// addressed, e.g. the target of a shortcut to a shared folder.
+func remoteId(driveId string, id string) string {
+	return driveId + "/" + id
+}
+
+// remoteDrive returns the drive of an id returned by remoteId, or "" for
+// nodes of the user's own drive and for all Live API ids, such as
+// "me/skydrive".
+func (d *OneDrive) remoteDrive(id string) string {
+	if !d.graph() {
+		return ""
+	}
+	driveId, _, _ := strings.Cut(id, "/")
+	if driveId == id {
+		return ""
+	}
+	return driveId
+}
+
+// qualify makes the ids of info, which was fetched from driveId, remote
+// ids so that they can be used in further requests.
+func qualify(info *NodeInfo, driveId string) {
+	if driveId == "" {
+		return
+	}
+	info.Id = remoteId(driveId, info.Id)
+	if info.ParentId != "" {
+		info.ParentId = remoteId(driveId, info.ParentId)
+	}
+}
+
+// childrenPath returns the API path of the node's listing.
+func (d *OneDrive) childrenPath(id string) string {
+	if d.graph() {
+		return d.itemPath(id) + "/children"
+	}
+	return d.itemPath(id) + "/files"
+}
+
+// childPath returns the API path addressing name inside the folder dirId.
+func (d *OneDrive) childPath(dirId string, name string) string {
+	if d.graph() {
+		return d.itemPath(dirId) + ":/" + name + ":"
+	}
+	return d.itemPath(dirId) + "/files/" + name
+}
+
+// nodeTarget returns the value a node response should be decoded into and
+// a func that fills in info from it once decoded. driveId is the drive the
+// node was fetched from, as returned by remoteDrive; ids of nodes of other
+// drives are qualified by it.
+func (d *OneDrive) nodeTarget(info *NodeInfo, driveId string) (target interface{}, finish func()) {
+	if !d.graph() {
+		return info, func() {}
+	}
+	item := &driveItem{}
+	return item, func() {
+		*info = item.nodeInfo()
+		qualify(info, driveId)
+	}
+}
+
+// driveItem is the Graph representation of a node.
+type driveItem struct {
+	Id                   string `json:"id"`
+	Name                 string `json:"name"`
+	Description          string `json:"description"`
+	Size                 int64  `json:"size"`
+	ETag                 string `json:"eTag"`
+	CreatedDateTime      string `json:"createdDateTime"`
+	LastModifiedDateTime string `json:"lastModifiedDateTime"`
+	DownloadUrl          string `json:"@microsoft.graph.downloadUrl"`
+	ParentReference      *struct {
+		Id string `json:"id"`
+	} `json:"parentReference"`
+	// RemoteItem is set on shortcuts to items in other drives.
+	RemoteItem *struct {
+		Id              string `json:"id"`
+		ParentReference struct {
+			DriveId string `json:"driveId"`
+		} `json:"parentReference"`
+	} `json:"remoteItem"`
+	Folder *struct {
+		ChildCount int `json:"childCount"`
+	} `json:"folder"`
+	File *struct {
+		MimeType string `json:"mimeType"`
+		Hashes   Hashes `json:"hashes"`
+	} `json:"file"`
+	Photo *struct {
+		TakenDateTime string `json:"takenDateTime"`
+		CameraMake    string `json:"cameraMake"`
+		CameraModel   string `json:"cameraModel"`
+	} `json:"photo"`
+	Image *struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"image"`
+	Location *Location `json:"location"`
+	// Deleted is set on items of a delta that were deleted.
+	Deleted *struct{} `json:"deleted"`
+	Video   *struct{} `json:"video"`
+	Audio   *struct{} `json:"audio"`
+
+	raw json.RawMessage
+}
+
+func (item *driveItem) UnmarshalJSON(data []byte) (err error) {
+	type driveItemFields driveItem
+	if err = json.Unmarshal(data, (*driveItemFields)(item)); err != nil {
+		return
+	}
+	item.raw = append(json.RawMessage(nil), data...)
+	return
+}
+
+func (item *driveItem) nodeType() string {
+	switch {
+	case item.RemoteItem != nil:
+		return "shortcut"
+	case item.Folder != nil:
+		return "folder"
+	case item.Photo != nil, item.Image != nil:
+		return "photo"
+	case item.Video != nil:
+		return "video"
+	case item.Audio != nil:
+		return "audio"
+	}
+	return "file"
+}
+
+func (item *driveItem) nodeInfo() NodeInfo {
+	info := NodeInfo{
+		Id:          item.Id,
+		Name:        item.Name,
+		Description: item.Description,
+		Size:        item.Size,
+		ETag:        item.ETag,
+		Type:        item.nodeType(),
+		CreatedTime: item.CreatedDateTime,
+		UpdatedTime: item.LastModifiedDateTime,
+		Source:      item.DownloadUrl,
+		RawJSON:     item.raw,
+	}
+	info.parseTimes()
+	if item.ParentReference != nil {
+		info.ParentId = item.ParentReference.Id
+	}
+	if item.File != nil {
+		info.Hashes = item.File.Hashes
+	}
+	if item.RemoteItem != nil {
+		info.Target = remoteId(item.RemoteItem.ParentReference.DriveId, item.RemoteItem.Id)
+	}
+	if info.NodeType() == NodeTypePhoto {
+		info.ImageInfo = item.imageInfo()
+	}
+	return info
+}
+
+func (item *driveItem) imageInfo() *ImageInfo {
+	image := &ImageInfo{Location: item.Location}
+	if item.Image != nil {
+		image.Width, image.Height = item.Image.Width, item.Image.Height
+	}
+	if item.Photo != nil {
+		image.TakenAt = parseTime(item.Photo.TakenDateTime)
+		image.CameraMake = item.Photo.CameraMake
+		image.CameraModel = item.Photo.CameraModel
+	}
+	return image
+}
+
+// graphPage is a page of a Graph listing.
+type graphPage struct {
+	Value    []driveItem `json:"value"`
+	NextLink string      `json:"@odata.nextLink"`
+	// DeltaLink is set on the last page of a delta.
+	DeltaLink string `json:"@odata.deltaLink"`
+}
+
+// nodeFiles converts the page, which was fetched from driveId, qualifying
+// its ids like nodeTarget.
+func (p *graphPage) nodeFiles(driveId string) (files NodeFiles) {
+	files.Data = make([]NodeInfo, len(p.Value))
+	for i := range p.Value {
+		files.Data[i] = p.Value[i].nodeInfo()
+		qualify(&files.Data[i], driveId)
+	}
+	files.Paging.Next = p.NextLink
+	return
+}
+
+// graphSearchPath returns the path of a search for query, which is quoted
+// as an OData string literal.
+func graphSearchPath(query string) string {
+	return "/me/drive/root/search(q='" + strings.Replace(query, "'", "''", -1) + "')"
+}
+
+func graphParentReference(parentId string) map[string]interface{} {
+	ref := map[string]string{"id": parentId}
+	if parentId == graphRootId {
+		ref = map[string]string{"path": "/drive/root"}
+	}
+	return map[string]interface{}{
+		"parentReference": ref,
+	}
+}
+
+// graphFilesPage emulates an offset listing by skipping whole pages.
+func (d *OneDrive) graphFilesPage(ctx context.Context, id string, offset int, limit int) (files []NodeInfo, hasMore bool, err error) {
+	params := url.Values{}
+	params.Set("$top", strconv.Itoa(limit))
+
+	resp, err := d.filesPage(ctx, d.remoteDrive(id), d.childrenPath(id), params, "")
+	for skipped := 0; err == nil; {
+		if skipped+len(resp.Data) > offset {
+			files = resp.Data[offset-skipped:]
+			break
+		}
+		skipped += len(resp.Data)
+		if resp.Paging.Next == "" || len(resp.Data) == 0 {
+			break
+		}
+		resp, err = d.filesPage(ctx, d.remoteDrive(id), "", nil, resp.Paging.Next)
+	}
+	if err != nil {
+		return
+	}
+
+	if len(files) > limit {
+		files = files[:limit]
+	}
+	hasMore = resp.Paging.Next != ""
+	return
+}
@@ -0,0 +1,91 @@
+package onedriveclient
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphPathEscaping(t *testing.T) {
+	tests := []struct {
+		name         string
+		childPath    string
+		childrenPath string
+		searchPath   string
+	}{
+		{
+			"a b.txt",
+			"/me/drive/root:/a%20b.txt:/content",
+			"/me/drive/items/a%20b.txt/children",
+			"/me/drive/root/search%28q=%27a%20b.txt%27%29",
+		},
+		{
+			"100% done.pdf",
+			"/me/drive/root:/100%25%20done.pdf:/content",
+			"/me/drive/items/100%25%20done.pdf/children",
+			"/me/drive/root/search%28q=%27100%25%20done.pdf%27%29",
+		},
+		{
+			"C++ notes.md",
+			"/me/drive/root:/C++%20notes.md:/content",
+			"/me/drive/items/C++%20notes.md/children",
+			"/me/drive/root/search%28q=%27C++%20notes.md%27%29",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			d := newGraphTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = append(got, r.URL.EscapedPath())
+				if strings.HasSuffix(r.URL.Path, ":/content") {
+					w.Write([]byte(`{"id":"1","name":"x","file":{}}`))
+					return
+				}
+				w.Write([]byte(`{"value":[]}`))
+			}))
+
+			if _, err := d.UploadOverwriteInfo("root", tt.name, true, strings.NewReader("x")); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := d.NodeFiles(tt.name); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := d.Search(tt.name); err != nil {
+				t.Fatal(err)
+			}
+
+			want := []string{tt.childPath, tt.childrenPath, tt.searchPath}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("got paths\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+		})
+	}
+}
+
+func TestCreateUploadSession(t *testing.T) {
+	d := newGraphTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/me/drive/root:/a.txt:/createUploadSession"; r.Method != "POST" || r.URL.Path != want {
+			t.Errorf("got %s %s, want POST %s", r.Method, r.URL.Path, want)
+		}
+		w.Write([]byte(`{"uploadUrl":"https://upload.example/1","expirationDateTime":"2026-01-02T03:04:05Z","nextExpectedRanges":["0-"]}`))
+	}))
+
+	session, err := d.CreateUploadSession("root", "a.txt", ConflictOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.UploadURL != "https://upload.example/1" {
+		t.Errorf("UploadURL = %s", session.UploadURL)
+	}
+	if !session.ExpiresAt.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("ExpiresAt = %v", session.ExpiresAt)
+	}
+	if session.DirId != "root" || session.Name != "a.txt" {
+		t.Errorf("session is for %s/%s, want root/a.txt", session.DirId, session.Name)
+	}
+	if len(session.NextExpectedRanges) != 1 || session.NextExpectedRanges[0] != "0-" {
+		t.Errorf("NextExpectedRanges = %q, want [0-]", session.NextExpectedRanges)
+	}
+}
@@ -0,0 +1,42 @@
+package onedriveclient
+
+import (
+	"context"
+	"net/http"
+)
+
+type headersKey struct{}
+
+// WithHeaders returns a context that makes every request sent with it carry
+// the given headers in addition to the ones the client sets. Use it with
+// the Context variants of the methods, e.g. to send Prefer or diagnostic
+// headers. Headers set by the client are replaced, except Authorization,
+// which cannot be overridden this way. Nested calls merge their headers.
+func WithHeaders(ctx context.Context, h http.Header) context.Context {
+	merged := requestHeaders(ctx).Clone()
+	if merged == nil {
+		merged = make(http.Header)
+	}
+	for key, values := range h {
+		merged[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	return context.WithValue(ctx, headersKey{}, merged)
+}
+
+func requestHeaders(ctx context.Context) http.Header {
+	if ctx == nil {
+		return nil
+	}
+	h, _ := ctx.Value(headersKey{}).(http.Header)
+	return h
+}
+
+// applyHeaders adds the headers of ctx to h.
+func applyHeaders(ctx context.Context, h http.Header) {
+	for key, values := range requestHeaders(ctx) {
+		if key == "Authorization" {
+			continue
+		}
+		h[key] = append([]string(nil), values...)
+	}
+}
@@ -0,0 +1,93 @@
+package onedriveclient
+
+import (
+	"context"
+)
+
+// ChildrenIterator lists the children of a node page by page, fetching the
+// next page only once the current one is used up:
+//
+//	it := d.Children(id)
+//	for it.Next() {
+//		info := it.Node()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// It is not safe for concurrent use.
+type ChildrenIterator struct {
+	d   *OneDrive
+	ctx context.Context
+	id  string
+
+	page    []NodeInfo
+	pos     int
+	next    string
+	started bool
+	done    bool
+	node    NodeInfo
+	err     error
+}
+
+func (d *OneDrive) Children(id string) *ChildrenIterator {
+	return d.ChildrenContext(context.Background(), id)
+}
+
+// ChildrenContext returns an iterator over the children of the node. No
+// request is made until the first call to Next.
+func (d *OneDrive) ChildrenContext(ctx context.Context, id string) *ChildrenIterator {
+	return &ChildrenIterator{d: d, ctx: ctx, id: id}
+}
+
+// Next advances to the next child, fetching the next page if needed. It
+// returns false once the listing is exhausted or a request failed; Err
+// tells which.
+func (it *ChildrenIterator) Next() bool {
+	for it.pos >= len(it.page) {
+		if it.done || it.err != nil {
+			return false
+		}
+		if err := it.fetch(); err != nil {
+			wrapOp(&err, "Children", it.id)
+			it.err = err
+			return false
+		}
+	}
+
+	it.node = it.page[it.pos]
+	it.pos++
+	return true
+}
+
+func (it *ChildrenIterator) fetch() (err error) {
+	if err = it.ctx.Err(); err != nil {
+		return
+	}
+
+	var resp NodeFiles
+	if !it.started {
+		resp, err = it.d.filesPage(it.ctx, it.d.remoteDrive(it.id), it.d.childrenPath(it.id), nil, "")
+	} else {
+		resp, err = it.d.filesPage(it.ctx, it.d.remoteDrive(it.id), "", nil, it.next)
+	}
+	if err != nil {
+		return
+	}
+
+	it.started = true
+	it.page, it.pos, it.next = resp.Data, 0, resp.Paging.Next
+	it.done = it.next == "" || len(resp.Data) == 0
+	return
+}
+
+// Node returns the child Next advanced to.
+func (it *ChildrenIterator) Node() NodeInfo {
+	return it.node
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ChildrenIterator) Err() error {
+	return it.err
+}
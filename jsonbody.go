@@ -0,0 +1,52 @@
+package onedriveclient
+
+import (
+	"context"
+	"github.com/koofr/go-httpclient"
+	"net/http"
+)
+
+// jsonRequest sends body, encoded as JSON, to the API path and decodes the
+// JSON response into result. body and result may be nil. header holds
+// headers to send besides the authentication header and may be nil.
+func (d *OneDrive) jsonRequest(ctx context.Context, method string, pth string, header http.Header, body interface{}, result interface{}, expectedStatus ...int) (err error) {
+	hs, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+	for key, values := range header {
+		hs[key] = values
+	}
+
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         method,
+		Path:           pth,
+		Headers:        hs,
+		ExpectedStatus: expectedStatus,
+	}
+	if body != nil {
+		hs.Set("Content-Type", "application/json")
+		req.ReqEncoding = httpclient.EncodingJSON
+		req.ReqValue = body
+	}
+	if result != nil {
+		req.RespEncoding = httpclient.EncodingJSON
+		req.RespValue = result
+	} else {
+		req.RespConsume = true
+	}
+
+	_, err = d.request(ctx, d.ApiClient, req)
+	return
+}
+
+// nodeRequest is jsonRequest for requests that respond with a node of
+// driveId (see nodeTarget).
+func (d *OneDrive) nodeRequest(ctx context.Context, method string, pth string, driveId string, header http.Header, body interface{}, expectedStatus ...int) (info NodeInfo, err error) {
+	target, finish := d.nodeTarget(&info, driveId)
+	if err = d.jsonRequest(ctx, method, pth, header, body, target, expectedStatus...); err == nil {
+		finish()
+	}
+	return
+}
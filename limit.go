@@ -0,0 +1,36 @@
+package onedriveclient
+
+import (
+	"context"
+	"sync"
+)
+
+// requestLimiter bounds the number of requests in flight. Its size is
+// fixed on first use.
+type requestLimiter struct {
+	once sync.Once
+	sem  chan struct{}
+}
+
+// acquire waits for a free slot or for ctx to be done. It does not block
+// if max is not positive.
+func (l *requestLimiter) acquire(ctx context.Context, max int) (release func(), err error) {
+	l.once.Do(func() {
+		if max > 0 {
+			l.sem = make(chan struct{}, max)
+		}
+	})
+	if l.sem == nil {
+		return func() {}, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	select {
+	case l.sem <- struct{}{}:
+		return func() { <-l.sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
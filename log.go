@@ -0,0 +1,89 @@
+package onedriveclient
+
+import (
+	"github.com/koofr/go-httpclient"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Logger is notified before and after every HTTP request the client makes,
+// including each retry. Implementations must be safe for concurrent use.
+type Logger interface {
+	BeforeRequest(entry RequestLog)
+	AfterRequest(entry RequestLog)
+}
+
+// RequestLog describes an HTTP request. Status, Duration and Err are only
+// set after the request. The Authorization header is redacted.
+type RequestLog struct {
+	Method   string
+	URL      string
+	Header   http.Header
+	Status   int
+	Duration time.Duration
+	Err      error
+}
+
+func redactHeader(h http.Header) http.Header {
+	h = h.Clone()
+	if h.Get("Authorization") != "" {
+		h.Set("Authorization", "REDACTED")
+	}
+	return h
+}
+
+// do performs a single request with d.UserAgent and the headers of its
+// context, reports it to d.Logger and records its throttling information
+// and d.Stats. It waits for a slot if d.MaxConcurrentRequests are in
+// flight.
+func (d *OneDrive) do(client *httpclient.HTTPClient, req *httpclient.RequestData) (res *http.Response, err error) {
+	release, err := d.limiter.acquire(req.Context, d.MaxConcurrentRequests)
+	if err != nil {
+		return
+	}
+	defer release()
+
+	if req.Headers == nil {
+		req.Headers = make(http.Header)
+	}
+	req.Headers.Set("User-Agent", userAgent(d.UserAgent))
+	applyHeaders(req.Context, req.Headers)
+
+	var entry RequestLog
+	if d.Logger != nil {
+		entry = RequestLog{
+			Method: req.Method,
+			URL:    req.FullURL,
+			Header: redactHeader(req.Headers),
+		}
+		if entry.URL == "" {
+			base := ""
+			if client.BaseURL != nil {
+				base = client.BaseURL.String()
+			}
+			entry.URL = strings.TrimSuffix(base, "/") + req.Path
+		}
+		d.Logger.BeforeRequest(entry)
+	}
+
+	start := time.Now()
+	res, err = client.Request(req)
+
+	status := 0
+	if res != nil {
+		status = res.StatusCode
+	} else if ise, ok := httpclient.IsInvalidStatusError(err); ok {
+		status = ise.Got
+	}
+	d.rateLimit.record(res, err)
+	d.Stats.countRequest(status)
+
+	if d.Logger != nil {
+		entry.Duration = time.Since(start)
+		entry.Status = status
+		entry.Err = err
+		d.Logger.AfterRequest(entry)
+	}
+	return
+}
@@ -0,0 +1,94 @@
+package onedriveclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// metadataFields are the fields UpdateMetadata may set, per backend.
+var metadataFields = map[Backend]map[string]bool{
+	BackendLive: {
+		"name":        true,
+		"description": true,
+	},
+	BackendGraph: {
+		"name":           true,
+		"description":    true,
+		"fileSystemInfo": true,
+	},
+}
+
+func (d *OneDrive) UpdateMetadata(id string, fields map[string]interface{}) (info NodeInfo, err error) {
+	return d.UpdateMetadataContext(context.Background(), id, fields)
+}
+
+// UpdateMetadataContext sets the given fields of the node and returns its
+// updated info. Only "name" and "description" can be set ("fileSystemInfo"
+// too with BackendGraph); other fields are rejected before anything is
+// sent.
+func (d *OneDrive) UpdateMetadataContext(ctx context.Context, id string, fields map[string]interface{}) (info NodeInfo, err error) {
+	defer wrapOp(&err, "UpdateMetadata", id)
+	return d.updateMetadata(ctx, id, fields, "")
+}
+
+func (d *OneDrive) UpdateMetadataIfMatch(id string, fields map[string]interface{}, etag string) (info NodeInfo, err error) {
+	return d.UpdateMetadataIfMatchContext(context.Background(), id, fields, etag)
+}
+
+// UpdateMetadataIfMatchContext is like UpdateMetadataContext but only
+// updates the node if its ETag still is etag. Otherwise the returned error
+// matches ErrPreconditionFailed.
+func (d *OneDrive) UpdateMetadataIfMatchContext(ctx context.Context, id string, fields map[string]interface{}, etag string) (info NodeInfo, err error) {
+	defer wrapOp(&err, "UpdateMetadataIfMatch", id)
+	return d.updateMetadata(ctx, id, fields, etag)
+}
+
+func (d *OneDrive) updateMetadata(ctx context.Context, id string, fields map[string]interface{}, ifMatch string) (info NodeInfo, err error) {
+	for field := range fields {
+		if !metadataFields[d.Backend][field] {
+			err = fmt.Errorf("Field %s cannot be updated", field)
+			return
+		}
+	}
+
+	var header http.Header
+	if ifMatch != "" {
+		header = make(http.Header)
+		header.Set("If-Match", ifMatch)
+	}
+
+	method := "PUT"
+	if d.graph() {
+		method = "PATCH"
+	}
+
+	info, err = d.nodeRequest(ctx, method, d.itemPath(id), d.remoteDrive(id), header, fields, 200)
+	if isPreconditionFailed(err) {
+		err = fmt.Errorf("%w %s", ErrPreconditionFailed, id)
+	}
+	if _, renamed := fields["name"]; renamed && isConflict(err) {
+		err = fmt.Errorf("%w %v", ErrConflict, fields["name"])
+	}
+	if err == nil {
+		if _, renamed := fields["name"]; renamed {
+			d.invalidate(id)
+		}
+	}
+	return
+}
+
+// setModTime sets the last modified time of the node. On failure the
+// returned info is the one passed in.
+func (d *OneDrive) setModTime(ctx context.Context, info NodeInfo, t time.Time) (NodeInfo, error) {
+	updated, err := d.UpdateMetadataContext(ctx, info.Id, map[string]interface{}{
+		"fileSystemInfo": map[string]string{
+			"lastModifiedDateTime": t.UTC().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return info, fmt.Errorf("Cannot set modification time of %s: %w", info.Name, opCause(err))
+	}
+	return updated, nil
+}
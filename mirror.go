@@ -0,0 +1,211 @@
+package onedriveclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MirrorOptions configures Mirror.
+type MirrorOptions struct {
+	// Delete removes remote files and folders that do not exist locally.
+	// Deleted nodes go to the recycle bin.
+	Delete bool
+}
+
+// MirrorResult counts what Mirror did. Folders are not counted.
+type MirrorResult struct {
+	Added   int
+	Updated int
+	Deleted int
+	Skipped int
+}
+
+func (d *OneDrive) Mirror(localDir string, remoteDir string, opts MirrorOptions) (result MirrorResult, err error) {
+	return d.MirrorContext(context.Background(), localDir, remoteDir, opts)
+}
+
+// MirrorContext makes the remote folder at the path remoteDir a copy of the
+// local directory localDir. Missing folders are created like EnsurePath
+// does. A file is uploaded unless a remote file of the same size exists
+// whose hash matches or, if the server reports no hash, which is newer than
+// the local file. Files of a folder are uploaded d.Concurrency at a time.
+// Symlinks and other special files are ignored.
+//
+// Failing files do not stop the mirror; if some fail, err is a *BatchError
+// keyed by local path.
+func (d *OneDrive) MirrorContext(ctx context.Context, localDir string, remoteDir string, opts MirrorOptions) (result MirrorResult, err error) {
+	defer wrapOp(&err, "Mirror", remoteDir)
+	root, err := d.EnsurePathContext(ctx, remoteDir)
+	if err != nil {
+		return
+	}
+
+	m := &mirror{
+		d:      d,
+		opts:   opts,
+		errors: make(map[string]error),
+	}
+	if err = m.dir(ctx, localDir, root.Id); err != nil {
+		return
+	}
+
+	result = m.result
+	if len(m.errors) > 0 {
+		err = &BatchError{Errors: m.errors}
+	}
+	return
+}
+
+// mirrorUpload is a local file and the remote file of the same name.
+type mirrorUpload struct {
+	name     string
+	existing NodeInfo
+	found    bool
+}
+
+type mirror struct {
+	d    *OneDrive
+	opts MirrorOptions
+
+	mu     sync.Mutex
+	result MirrorResult
+	errors map[string]error
+}
+
+func (m *mirror) fail(localPath string, err error) {
+	m.mu.Lock()
+	m.errors[localPath] = err
+	m.mu.Unlock()
+}
+
+func (m *mirror) count(counter *int) {
+	m.mu.Lock()
+	*counter++
+	m.mu.Unlock()
+}
+
+// dir mirrors the local directory into the remote folder dirId. Only
+// failures to read either listing are returned; other failures are
+// recorded in m.errors.
+func (m *mirror) dir(ctx context.Context, localDir string, dirId string) (err error) {
+	entries, err := os.ReadDir(localDir)
+	if err != nil {
+		return
+	}
+	files, err := m.d.NodeFilesContext(ctx, dirId)
+	if err != nil {
+		return
+	}
+
+	remote := make(map[string]NodeInfo, len(files))
+	for _, file := range files {
+		remote[NormalizeName(file.Name)] = file
+	}
+
+	var uploads []mirrorUpload
+	for _, entry := range entries {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		localPath := filepath.Join(localDir, entry.Name())
+		key := NormalizeName(entry.Name())
+		existing, found := remote[key]
+		delete(remote, key)
+
+		switch {
+		case entry.IsDir():
+			if err := m.subdir(ctx, localPath, dirId, entry.Name(), existing, found); err != nil {
+				m.fail(localPath, err)
+			}
+		case entry.Type().IsRegular():
+			uploads = append(uploads, mirrorUpload{entry.Name(), existing, found})
+		}
+	}
+
+	m.d.forEach(ctx, len(uploads), func(i int) {
+		u := uploads[i]
+		localPath := filepath.Join(localDir, u.name)
+		if err := m.file(ctx, localPath, dirId, u.name, u.existing, u.found); err != nil {
+			m.fail(localPath, err)
+		}
+	})
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	if !m.opts.Delete {
+		return
+	}
+	for _, file := range remote {
+		if err := m.d.DeleteContext(ctx, file.Id); err != nil {
+			m.fail(filepath.Join(localDir, file.Name), err)
+			continue
+		}
+		if !file.IsDir() {
+			m.count(&m.result.Deleted)
+		}
+	}
+	return ctx.Err()
+}
+
+func (m *mirror) subdir(ctx context.Context, localPath string, dirId string, name string, existing NodeInfo, found bool) (err error) {
+	if found && !existing.IsDir() {
+		return fmt.Errorf("%w %s", ErrNotFolder, localPath)
+	}
+	if !found {
+		if existing, err = m.d.CreateFolderContext(ctx, dirId, name); err != nil {
+			return
+		}
+	}
+	return m.dir(ctx, localPath, existing.Id)
+}
+
+func (m *mirror) file(ctx context.Context, localPath string, dirId string, name string, existing NodeInfo, found bool) (err error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return
+	}
+
+	if found && existing.IsFile() && existing.Size == stat.Size() {
+		var same bool
+		if existing.Hashes == (Hashes{}) {
+			same = !existing.UpdatedAt.IsZero() && !stat.ModTime().After(existing.UpdatedAt)
+		} else if same, err = sameContent(existing.Hashes, f); err != nil {
+			return
+		}
+		if same {
+			m.count(&m.result.Skipped)
+			return
+		}
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+	}
+
+	info, err := m.d.uploadSized(ctx, dirId, name, f, stat.Size(), true)
+	if err != nil {
+		return
+	}
+	if m.d.graph() {
+		if _, err = m.d.setModTime(ctx, info, stat.ModTime()); err != nil {
+			return
+		}
+	}
+
+	if found {
+		m.count(&m.result.Updated)
+	} else {
+		m.count(&m.result.Added)
+	}
+	return
+}
@@ -0,0 +1,68 @@
+package onedriveclient
+
+import (
+	"context"
+	"golang.org/x/text/unicode/norm"
+	"sort"
+	"strings"
+)
+
+// NormalizeName returns the form under which the server compares names:
+// Unicode NFC, case-folded. Names with the same normal form, such as
+// "Café.txt" written with a precomposed and with a combining accent, refer
+// to the same node.
+func NormalizeName(name string) string {
+	// upper then lower folds characters like U+017F (long s) that have no
+	// lowercase mapping of their own
+	return strings.ToLower(strings.ToUpper(norm.NFC.String(name)))
+}
+
+// SameName reports whether the server treats the names as the same.
+func SameName(a string, b string) bool {
+	return NormalizeName(a) == NormalizeName(b)
+}
+
+// NameCollisions groups the names that would refer to the same node when
+// uploaded into one folder. Only groups of two or more names are returned,
+// each sorted, keyed by their NormalizeName.
+func NameCollisions(names []string) (collisions map[string][]string) {
+	groups := make(map[string][]string)
+	for _, name := range names {
+		key := NormalizeName(name)
+		groups[key] = append(groups[key], name)
+	}
+
+	collisions = make(map[string][]string)
+	for key, group := range groups {
+		if len(group) > 1 {
+			sort.Strings(group)
+			collisions[key] = group
+		}
+	}
+	return
+}
+
+func (d *OneDrive) Collision(dirId string, name string) (existing NodeInfo, found bool, err error) {
+	return d.CollisionContext(context.Background(), dirId, name)
+}
+
+// CollisionContext returns the child of dirId an upload called name would
+// replace or be renamed for. Names are compared like the server does,
+// regardless of d.CaseSensitivePaths, so that an upload does not
+// unexpectedly overwrite a file whose name only differs in case or Unicode
+// normalization.
+func (d *OneDrive) CollisionContext(ctx context.Context, dirId string, name string) (existing NodeInfo, found bool, err error) {
+	defer wrapOp(&err, "Collision", dirId+"/"+name)
+	files, err := d.NodeFilesContext(ctx, dirId)
+	if err != nil {
+		return
+	}
+
+	key := NormalizeName(name)
+	for _, file := range files {
+		if NormalizeName(file.Name) == key {
+			return file, true, nil
+		}
+	}
+	return
+}
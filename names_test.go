@@ -0,0 +1,92 @@
+package onedriveclient
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSameName(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"caf\u00E9.txt", "cafe\u0301.txt", true},
+		{"caf\u00E9.txt", "CAF\u00C9.TXT", true},
+		{"cafe\u0301.txt", "CAF\u00C9.TXT", true},
+		{"\u017Fample", "sample", true},
+		{"\u017Fample", "SAMPLE", true},
+		{"cafe.txt", "caf\u00E9.txt", false},
+		{"a.txt", "a.txt ", false},
+	}
+
+	for _, tt := range tests {
+		if got := SameName(tt.a, tt.b); got != tt.want {
+			t.Errorf("SameName(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"caf\u00E9.txt", "caf\u00E9.txt"},
+		{"cafe\u0301.txt", "caf\u00E9.txt"},
+		{"CAF\u00C9.TXT", "caf\u00E9.txt"},
+		{"\u017Fample", "sample"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeName(tt.name); got != tt.want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNameCollisions(t *testing.T) {
+	got := NameCollisions([]string{
+		"caf\u00E9.txt",
+		"cafe\u0301.txt",
+		"CAF\u00C9.TXT",
+		"sample",
+		"\u017Fample",
+		"unique.txt",
+	})
+	want := map[string][]string{
+		"caf\u00E9.txt": {"CAF\u00C9.TXT", "cafe\u0301.txt", "caf\u00E9.txt"},
+		"sample":        {"sample", "\u017Fample"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NameCollisions = %q, want %q", got, want)
+	}
+}
+
+func TestMatchChildExact(t *testing.T) {
+	files := []NodeInfo{
+		{Id: "file.1", Name: "A.txt"},
+		{Id: "file.2", Name: "a.txt"},
+		{Id: "file.3", Name: "caf\u00E9.txt"},
+	}
+
+	tests := []struct {
+		name  string
+		id    string
+		found bool
+		err   error
+	}{
+		{"a.txt", "file.2", true, nil},
+		{"A.txt", "file.1", true, nil},
+		{"cafe\u0301.txt", "file.3", true, nil},
+		{"A.TXT", "", false, ErrAmbiguous},
+		{"b.txt", "", false, nil},
+	}
+
+	for _, tt := range tests {
+		child, found, err := matchChildExact(files, tt.name)
+		if child.Id != tt.id || found != tt.found || !errors.Is(err, tt.err) {
+			t.Errorf("matchChildExact(%q) = %s, %v, %v; want %s, %v, %v", tt.name, child.Id, found, err, tt.id, tt.found, tt.err)
+		}
+	}
+}
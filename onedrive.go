@@ -1,81 +1,151 @@
 package onedriveclient
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/koofr/go-httpclient"
 	"github.com/koofr/go-ioutils"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
-	"path"
-	"strings"
+	"strconv"
 	"time"
 )
 
 type OneDrive struct {
 	ApiClient     *httpclient.HTTPClient
 	ContentClient *httpclient.HTTPClient
+	// SessionClient talks to the OneDrive API, which provides resumable
+	// upload sessions.
+	SessionClient *httpclient.HTTPClient
 	Auth          *OneDriveAuth
-}
+	// Backend is the API the client talks to. It has to match the base URL
+	// of ApiClient.
+	Backend Backend
 
-type OneDriveAuth struct {
-	ClientId     string
-	ClientSecret string
-	RedirectUri  string
-	AccessToken  string
-	RefreshToken string
-	ExpiresAt    time.Time
-}
+	// MaxRetries is the number of times a throttled or failed request is
+	// retried. Zero disables retries.
+	MaxRetries int
+	// BaseBackoff is the initial delay between retries when the server does
+	// not send Retry-After. It doubles with every attempt.
+	BaseBackoff time.Duration
 
-func (d *OneDriveAuth) ValidToken() (token string, err error) {
-	if time.Now().Unix() > d.ExpiresAt.Unix() {
-		data := url.Values{}
-		data.Set("grant_type", "refresh_token")
-		data.Set("client_id", d.ClientId)
-		data.Set("client_secret", d.ClientSecret)
-		data.Set("redirect_uri", d.RedirectUri)
-		data.Set("refresh_token", d.RefreshToken)
+	// PathCache, if set, caches path resolution in ResolvePath. It is not
+	// used when CaseSensitivePaths is set.
+	PathCache *PathCache
+	// CaseSensitivePaths makes path resolution compare names exactly
+	// instead of ignoring case.
+	CaseSensitivePaths bool
+	// FollowShortcuts makes path resolution continue into the targets of
+	// shortcuts to shared folders. Otherwise a shortcut in the middle of a
+	// path is an error matching ErrShortcut.
+	FollowShortcuts bool
 
-		var resp *http.Response
+	// Concurrency is the number of requests batch operations such as
+	// NodeInfos run in parallel. It defaults to DefaultConcurrency.
+	Concurrency int
+	// MaxConcurrentRequests, if positive, limits the number of requests
+	// the client has in flight at any time, across all operations. A
+	// request holds its slot until its response headers arrive, not while
+	// its body is read. It has to be set before the first request. Token
+	// refreshes are not limited.
+	MaxConcurrentRequests int
 
-		resp, err = http.PostForm("https://login.live.com/oauth20_token.srf", data)
-		if err != nil {
-			return
-		}
+	// UploadChunkSize is the chunk size used by UploadSession. It is rounded
+	// down to a multiple of UploadChunkAlignment and defaults to
+	// DefaultUploadChunkSize.
+	UploadChunkSize int64
+	// SimpleUploadMaxBytes is the largest upload of known size sent in a
+	// single request by UploadFile and UploadOverwrite; larger ones use an
+	// upload session with BackendGraph (see ShouldUseSession). It defaults
+	// to DefaultSimpleUploadMaxBytes.
+	SimpleUploadMaxBytes int64
+	// UploadReadAhead is the number of chunks UploadSession reads ahead
+	// while a chunk is being sent, so that reading the content overlaps
+	// with the upload. The API only accepts chunks in order, so they are
+	// never sent in parallel. Each chunk buffered costs UploadChunkSize
+	// bytes of memory.
+	UploadReadAhead int
 
-		if resp.StatusCode != 200 {
-			err = fmt.Errorf("Token refresh failed %d: %s", resp.StatusCode, resp.Status)
-			return
-		}
+	// UserAgent is sent with every request. It defaults to
+	// DefaultUserAgent. Set Auth.UserAgent for token refreshes.
+	UserAgent string
 
-		var buf []byte
-		if buf, err = ioutil.ReadAll(resp.Body); err != nil {
-			return
-		}
+	// Logger, if set, is notified of every API request. Set Auth.Logger to
+	// also log token refreshes.
+	Logger Logger
+	// Stats, if set, counts requests, retries and throttled responses.
+	Stats *Stats
+
+	ancestors ancestorCache
+	rateLimit rateLimitState
+	limiter   requestLimiter
+}
 
-		var respVal RefreshResp
-		if err = json.Unmarshal(buf, &respVal); err != nil {
+func NewOneDriveClient(auth OneDriveAuth) *OneDrive {
+	return NewOneDriveClientWithClients(auth, httpclient.New(), httpclient.New())
+}
+
+// NewOneDriveClientWithBaseURL is like NewOneDriveClient but sends API
+// requests to baseUrl, e.g. a regional endpoint or a test server. An empty
+// baseUrl selects the Live API.
+func NewOneDriveClientWithBaseURL(auth OneDriveAuth, baseUrl string) (d *OneDrive, err error) {
+	api := httpclient.New()
+	if baseUrl != "" {
+		if api.BaseURL, err = url.Parse(baseUrl); err != nil {
 			return
 		}
-
-		d.AccessToken = respVal.AccessToken
-		d.ExpiresAt = time.Now().Add(time.Duration(respVal.ExpiresIn) * time.Second)
 	}
-	token = d.AccessToken
+	d = NewOneDriveClientWithClients(auth, api, httpclient.New())
 	return
 }
 
-func NewOneDriveClient(auth OneDriveAuth) *OneDrive {
-	apiBaseUrl, _ := url.Parse("https://apis.live.net/v5.0")
-	apiHttpClient := httpclient.New()
-	apiHttpClient.BaseURL = apiBaseUrl
-	return &OneDrive{apiHttpClient, httpclient.New(), &auth}
+// NewOneDriveClientWithClients is like NewOneDriveClient but uses the given
+// clients for API and content requests, e.g. to configure timeouts, a proxy
+// or a custom transport. The API client's BaseURL is set to the Live API if
+// it is nil. Unless auth.HTTPClient is set, token refreshes go through the
+// API client's http.Client.
+func NewOneDriveClientWithClients(auth OneDriveAuth, api *httpclient.HTTPClient, content *httpclient.HTTPClient) *OneDrive {
+	if api.BaseURL == nil {
+		api.BaseURL, _ = url.Parse(liveBaseUrl)
+	}
+	if auth.HTTPClient == nil {
+		auth.HTTPClient = api.Client
+	}
+	sessionBaseUrl, _ := url.Parse("https://api.onedrive.com/v1.0")
+	sessionHttpClient := httpclient.New()
+	sessionHttpClient.BaseURL = sessionBaseUrl
+	sessionHttpClient.Client = api.Client
+	return &OneDrive{
+		ApiClient:     api,
+		ContentClient: content,
+		SessionClient: sessionHttpClient,
+		Auth:          &auth,
+		MaxRetries:    DefaultMaxRetries,
+		BaseBackoff:   DefaultBaseBackoff,
+	}
 }
 
 func (d *OneDrive) AuthenticationHeader() (hs http.Header, err error) {
-	token, err := d.Auth.ValidToken()
+	return d.AuthenticationHeaderContext(context.Background())
+}
+
+func (d *OneDrive) AuthenticationHeaderContext(ctx context.Context) (hs http.Header, err error) {
+	hs, _, err = d.AuthenticationHeaderRefreshedContext(ctx)
+	return
+}
+
+func (d *OneDrive) AuthenticationHeaderRefreshed() (hs http.Header, refreshed bool, err error) {
+	return d.AuthenticationHeaderRefreshedContext(context.Background())
+}
+
+// AuthenticationHeaderRefreshedContext is like AuthenticationHeaderContext
+// and reports whether the token was refreshed by this call, in which case
+// d.Auth holds new credentials that may need to be persisted.
+func (d *OneDrive) AuthenticationHeaderRefreshedContext(ctx context.Context) (hs http.Header, refreshed bool, err error) {
+	token, refreshed, err := d.Auth.validToken(ctx)
 	if err != nil {
 		return
 	}
@@ -87,178 +157,920 @@ func (d *OneDrive) AuthenticationHeader() (hs http.Header, err error) {
 }
 
 func (d *OneDrive) NodeInfo(id string) (info NodeInfo, err error) {
-	header, err := d.AuthenticationHeader()
+	return d.NodeInfoContext(context.Background(), id)
+}
+
+// NodeInfoContext returns the info of the node. If it does not exist the
+// returned error is a *NodeNotFoundError.
+func (d *OneDrive) NodeInfoContext(ctx context.Context, id string) (info NodeInfo, err error) {
+	defer wrapOp(&err, "NodeInfo", id)
+	return d.nodeInfo(ctx, id, "")
+}
+
+// nodeInfo fetches the info of the node. If ifNoneMatch is set and the node
+// still has that ETag, the returned error matches ErrNotModified.
+func (d *OneDrive) nodeInfo(ctx context.Context, id string, ifNoneMatch string) (info NodeInfo, err error) {
+	header, err := d.AuthenticationHeaderContext(ctx)
 	if err != nil {
 		return
 	}
+	if ifNoneMatch != "" {
+		header.Set("If-None-Match", ifNoneMatch)
+	}
 
+	target, finish := d.nodeTarget(&info, d.remoteDrive(id))
 	req := &httpclient.RequestData{
+		Context:        ctx,
 		Method:         "GET",
-		Path:           "/" + id,
+		Path:           d.itemPath(id),
 		Headers:        header,
 		ExpectedStatus: []int{200},
 		RespEncoding:   httpclient.EncodingJSON,
-		RespValue:      &info,
+		RespValue:      target,
+	}
+	_, err = d.request(ctx, d.ApiClient, req)
+	if isStatus(err, http.StatusNotFound) {
+		err = &NodeNotFoundError{Id: id}
+	}
+	if isStatus(err, http.StatusNotModified) {
+		err = fmt.Errorf("%w %s", ErrNotModified, id)
+	}
+	if err == nil {
+		finish()
+	}
+	return
+}
+
+func (d *OneDrive) DownloadIfChanged(id string, etag string) (info NodeInfo, content io.ReadCloser, err error) {
+	return d.DownloadIfChangedContext(context.Background(), id, etag)
+}
+
+// DownloadIfChangedContext downloads the whole content of the node unless
+// its ETag still is etag, in which case the returned error matches
+// ErrNotModified and nothing is downloaded.
+func (d *OneDrive) DownloadIfChangedContext(ctx context.Context, id string, etag string) (info NodeInfo, content io.ReadCloser, err error) {
+	defer wrapOp(&err, "DownloadIfChanged", id)
+	info, err = d.nodeInfo(ctx, id, etag)
+	if err != nil {
+		return
 	}
-	_, err = d.ApiClient.Request(req)
+
+	res, err := d.downloadSource(ctx, info, "")
 	if err != nil {
 		return
 	}
+
+	info.Size = res.ContentLength
+	content = newContextReadCloser(ctx, res.Body)
 	return
 }
 
 func (d *OneDrive) RootInfo() (info NodeInfo, err error) {
-	info, err = d.NodeInfo("me/skydrive")
+	return d.RootInfoContext(context.Background())
+}
+
+func (d *OneDrive) RootInfoContext(ctx context.Context) (info NodeInfo, err error) {
+	defer wrapOp(&err, "RootInfo", "root")
+	info, err = d.NodeInfoContext(ctx, d.rootId())
 	return
 }
 
 func (d *OneDrive) NodeFiles(id string) (files []NodeInfo, err error) {
-	header, err := d.AuthenticationHeader()
+	return d.NodeFilesContext(context.Background(), id)
+}
+
+// NodeFilesContext lists all children of the node, following the paging
+// links until the listing is exhausted.
+func (d *OneDrive) NodeFilesContext(ctx context.Context, id string) (files []NodeInfo, err error) {
+	defer wrapOp(&err, "NodeFiles", id)
+	return d.allPages(ctx, d.remoteDrive(id), d.childrenPath(id), nil)
+}
+
+func (d *OneDrive) NodeFolders(id string) (folders []NodeInfo, err error) {
+	return d.NodeFoldersContext(context.Background(), id)
+}
+
+// NodeFoldersContext lists the children of the node that are folders (or
+// albums). The Live API filters the listing on the server.
+func (d *OneDrive) NodeFoldersContext(ctx context.Context, id string) (folders []NodeInfo, err error) {
+	defer wrapOp(&err, "NodeFolders", id)
+	if d.graph() {
+		return d.filterFiles(ctx, id, NodeInfo.IsDir)
+	}
+	params := url.Values{}
+	params.Set("filter", "folders,albums")
+	return d.allPages(ctx, "", d.childrenPath(id), params)
+}
+
+func (d *OneDrive) NodeFilesOnly(id string) (files []NodeInfo, err error) {
+	return d.NodeFilesOnlyContext(context.Background(), id)
+}
+
+// NodeFilesOnlyContext lists the children of the node that are not
+// folders.
+func (d *OneDrive) NodeFilesOnlyContext(ctx context.Context, id string) (files []NodeInfo, err error) {
+	defer wrapOp(&err, "NodeFilesOnly", id)
+	return d.filterFiles(ctx, id, func(info NodeInfo) bool { return !info.IsDir() })
+}
+
+func (d *OneDrive) filterFiles(ctx context.Context, id string, keep func(NodeInfo) bool) (files []NodeInfo, err error) {
+	all, err := d.NodeFilesContext(ctx, id)
+	if err != nil {
+		return
+	}
+	files = make([]NodeInfo, 0, len(all))
+	for _, file := range all {
+		if keep(file) {
+			files = append(files, file)
+		}
+	}
+	return
+}
+
+// allPages fetches a listing of driveId and all of its following pages.
+func (d *OneDrive) allPages(ctx context.Context, driveId string, pth string, params url.Values) (files []NodeInfo, err error) {
+	resp, err := d.filesPage(ctx, driveId, pth, params, "")
+	if err != nil {
+		return
+	}
+	files = resp.Data
+
+	for resp.Paging.Next != "" && len(resp.Data) > 0 {
+		if resp, err = d.filesPage(ctx, driveId, "", nil, resp.Paging.Next); err != nil {
+			return nil, err
+		}
+		files = append(files, resp.Data...)
+	}
+	return
+}
+
+// NodeFilesIter is like NodeFilesIterContext with a background context. The
+// entries channel has to be drained to release the fetching goroutine.
+func (d *OneDrive) NodeFilesIter(id string) (<-chan NodeInfo, <-chan error) {
+	return d.NodeFilesIterContext(context.Background(), id)
+}
+
+// NodeFilesIterContext streams the children of the node as pages arrive.
+// The entries channel is closed when the listing is exhausted, fails or ctx
+// is done; afterwards the error channel yields the error, if any, and is
+// closed.
+func (d *OneDrive) NodeFilesIterContext(ctx context.Context, id string) (<-chan NodeInfo, <-chan error) {
+	entries := make(chan NodeInfo)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(errc)
+		defer close(entries)
+
+		fail := func(err error) {
+			wrapOp(&err, "NodeFilesIter", id)
+			errc <- err
+		}
+
+		resp, err := d.filesPage(ctx, d.remoteDrive(id), d.childrenPath(id), nil, "")
+		for {
+			if err != nil {
+				fail(err)
+				return
+			}
+			for _, info := range resp.Data {
+				select {
+				case entries <- info:
+				case <-ctx.Done():
+					fail(ctx.Err())
+					return
+				}
+			}
+			if resp.Paging.Next == "" || len(resp.Data) == 0 {
+				return
+			}
+			resp, err = d.filesPage(ctx, d.remoteDrive(id), "", nil, resp.Paging.Next)
+		}
+	}()
+
+	return entries, errc
+}
+
+func (d *OneDrive) NodeFilesPage(id string, offset int, limit int) (files []NodeInfo, hasMore bool, err error) {
+	return d.NodeFilesPageContext(context.Background(), id, offset, limit)
+}
+
+// NodeFilesPageContext lists at most limit children of the node starting
+// at offset. hasMore reports whether there are further pages. Graph does
+// not support offsets, so with BackendGraph the preceding pages are
+// fetched and skipped.
+func (d *OneDrive) NodeFilesPageContext(ctx context.Context, id string, offset int, limit int) (files []NodeInfo, hasMore bool, err error) {
+	defer wrapOp(&err, "NodeFilesPage", id)
+	if d.graph() {
+		return d.graphFilesPage(ctx, id, offset, limit)
+	}
+
+	params := url.Values{}
+	params.Set("offset", strconv.Itoa(offset))
+	params.Set("limit", strconv.Itoa(limit))
+
+	resp, err := d.filesPage(ctx, "", d.childrenPath(id), params, "")
 	if err != nil {
 		return
 	}
 
-	var resp NodeFiles
+	files = resp.Data
+	hasMore = resp.Paging.Next != ""
+	return
+}
+
+// filesPage fetches one page of a listing, either by path and params or by
+// the full URL of a paging link.
+func (d *OneDrive) filesPage(ctx context.Context, driveId string, pth string, params url.Values, fullUrl string) (resp NodeFiles, err error) {
+	header, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+
+	var page graphPage
+	var target interface{} = &resp
+	if d.graph() {
+		target = &page
+	}
+
 	req := &httpclient.RequestData{
+		Context:        ctx,
 		Method:         "GET",
-		Path:           "/" + id + "/files",
+		Path:           pth,
+		Params:         params,
+		FullURL:        fullUrl,
 		Headers:        header,
 		ExpectedStatus: []int{200},
 		RespEncoding:   httpclient.EncodingJSON,
-		RespValue:      &resp,
+		RespValue:      target,
 	}
-	_, err = d.ApiClient.Request(req)
-	if err != nil {
-		return
+	_, err = d.request(ctx, d.ApiClient, req)
+	if err == nil && d.graph() {
+		resp = page.nodeFiles(driveId)
 	}
-
-	files = resp.Data
 	return
 }
 
 func (d *OneDrive) Download(id string, span *ioutils.FileSpan) (info NodeInfo, content io.ReadCloser, err error) {
-	info, err = d.NodeInfo(id)
+	return d.DownloadContext(context.Background(), id, span)
+}
+
+// DownloadContext is like Download but bound to ctx. If ctx is canceled
+// while the content is being read, the content is closed and subsequent
+// reads return ctx.Err().
+func (d *OneDrive) DownloadContext(ctx context.Context, id string, span *ioutils.FileSpan) (info NodeInfo, content io.ReadCloser, err error) {
+	defer wrapOp(&err, "Download", id)
+	info, err = d.NodeInfoContext(ctx, id)
 	if err != nil {
 		return
 	}
 
-	url := info.Source
-	if url == "" {
-		err = fmt.Errorf("Cannot download %s", id)
+	return d.downloadInfo(ctx, info, span)
+}
+
+func (d *OneDrive) DownloadPath(pth string, span *ioutils.FileSpan) (info NodeInfo, content io.ReadCloser, err error) {
+	return d.DownloadPathContext(context.Background(), pth, span)
+}
+
+// DownloadPathContext is like DownloadContext but looks the node up by
+// path. The info comes from the parent's listing, so no extra NodeInfo
+// request is made. A missing path is an error matching ErrNotFound.
+func (d *OneDrive) DownloadPathContext(ctx context.Context, pth string, span *ioutils.FileSpan) (info NodeInfo, content io.ReadCloser, err error) {
+	defer wrapOp(&err, "DownloadPath", pth)
+	info, err = d.ResolvePathInfoContext(ctx, pth)
+	if err != nil {
 		return
 	}
 
-	req := httpclient.RequestData{
-		Method:         "GET",
-		FullURL:        url,
-		ExpectedStatus: []int{http.StatusOK, http.StatusPartialContent},
-	}
+	return d.downloadInfo(ctx, info, span)
+}
+
+// downloadInfo downloads the content of the node described by info.
+func (d *OneDrive) downloadInfo(ctx context.Context, node NodeInfo, span *ioutils.FileSpan) (info NodeInfo, content io.ReadCloser, err error) {
+	info = node
 
+	var rng string
 	if span != nil {
-		req.Headers = make(http.Header)
-		req.Headers.Set("Range", fmt.Sprintf("bytes=%d-%d", span.Start, span.End))
+		rng = fmt.Sprintf("bytes=%d-%d", span.Start, span.End)
 	}
 
-	res, err := d.ContentClient.Request(&req)
+	res, err := d.downloadSource(ctx, info, rng)
 	if err != nil {
 		return
 	}
 
 	info.Size = res.ContentLength
 
-	content = res.Body
+	content = newContextReadCloser(ctx, res.Body)
 	return
 }
 
+// contentSource returns where the content of the node is downloaded from:
+// info.Source or, for Graph nodes without a download URL, the item's
+// content endpoint, which is relative to the API.
+func (d *OneDrive) contentSource(info NodeInfo) string {
+	if info.Source == "" && d.graph() && info.IsFile() {
+		return d.itemPath(info.Id) + "/content"
+	}
+	return info.Source
+}
+
+// downloadSource requests the content of the node, sending rng as the
+// Range header unless it is empty. Absolute sources are pre-signed and are
+// fetched with d.ContentClient; relative ones are API paths fetched with
+// d.ApiClient and the authentication header.
+func (d *OneDrive) downloadSource(ctx context.Context, info NodeInfo, rng string) (res *http.Response, err error) {
+	source := d.contentSource(info)
+	if source == "" {
+		err = fmt.Errorf("Cannot download %s", info.Id)
+		return
+	}
+	location, err := url.Parse(source)
+	if err != nil {
+		return
+	}
+
+	// Redirects are followed here rather than by http.Client so that the
+	// Range header is sent to the final location whatever the client's
+	// redirect policy.
+	for redirects := 0; ; redirects++ {
+		req := httpclient.RequestData{
+			Context:         ctx,
+			Method:          "GET",
+			Headers:         make(http.Header),
+			ExpectedStatus:  []int{http.StatusOK, http.StatusPartialContent, http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect},
+			IgnoreRedirects: true,
+		}
+
+		client := d.ContentClient
+		if location.IsAbs() {
+			req.FullURL = location.String()
+		} else {
+			client = d.ApiClient
+			if req.Headers, err = d.AuthenticationHeaderContext(ctx); err != nil {
+				return
+			}
+			req.Path = location.Path
+			req.Params = location.Query()
+		}
+
+		if rng != "" {
+			req.Headers.Set("Range", rng)
+		}
+
+		res, err = d.request(ctx, client, &req)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return
+		}
+		if res.StatusCode == http.StatusOK || res.StatusCode == http.StatusPartialContent {
+			return
+		}
+
+		res.Body.Close()
+		next, lerr := location.Parse(res.Header.Get("Location"))
+		if lerr != nil || res.Header.Get("Location") == "" {
+			return nil, fmt.Errorf("Cannot download %s: invalid redirect", info.Id)
+		}
+		if redirects >= maxDownloadRedirects {
+			return nil, fmt.Errorf("Cannot download %s: too many redirects", info.Id)
+		}
+		location = next
+	}
+}
+
+// maxDownloadRedirects is the number of redirects downloadSource follows.
+const maxDownloadRedirects = 10
+
 func (d *OneDrive) Upload(dirId string, name string, content io.Reader) (err error) {
-	_, err = d.UploadOverwrite(dirId, name, true, content)
+	return d.UploadContext(context.Background(), dirId, name, content)
+}
+
+func (d *OneDrive) UploadContext(ctx context.Context, dirId string, name string, content io.Reader) (err error) {
+	defer wrapOp(&err, "Upload", dirId+"/"+name)
+	_, err = d.UploadOverwriteContext(ctx, dirId, name, true, content)
 
 	return
 }
 
 func (d *OneDrive) UploadOverwrite(dirId string, name string, overwrite bool, content io.Reader) (newName string, err error) {
-	header, err := d.AuthenticationHeader()
+	return d.UploadOverwriteContext(context.Background(), dirId, name, overwrite, content)
+}
+
+func (d *OneDrive) UploadOverwriteContext(ctx context.Context, dirId string, name string, overwrite bool, content io.Reader) (newName string, err error) {
+	defer wrapOp(&err, "UploadOverwrite", dirId+"/"+name)
+	info, err := d.UploadOverwriteInfoContext(ctx, dirId, name, overwrite, content)
+	if err != nil {
+		return
+	}
+
+	newName = info.Name
+
+	return
+}
+
+// UploadResult describes the outcome of an upload.
+type UploadResult struct {
+	// Info is the uploaded file as reported by the server.
+	Info NodeInfo
+	// RequestedName is the name the upload was requested under.
+	RequestedName string
+	// Renamed is set if the server stored the file under a different name
+	// to avoid a conflict.
+	Renamed bool
+}
+
+func (d *OneDrive) UploadOverwriteResult(dirId string, name string, overwrite bool, content io.Reader) (result UploadResult, err error) {
+	return d.UploadOverwriteResultContext(context.Background(), dirId, name, overwrite, content)
+}
+
+// UploadOverwriteResultContext is like UploadOverwriteContext but reports
+// whether the server renamed the file.
+func (d *OneDrive) UploadOverwriteResultContext(ctx context.Context, dirId string, name string, overwrite bool, content io.Reader) (result UploadResult, err error) {
+	defer wrapOp(&err, "UploadOverwriteResult", dirId+"/"+name)
+	info, err := d.UploadOverwriteInfoContext(ctx, dirId, name, overwrite, content)
 	if err != nil {
 		return
 	}
 
+	result = UploadResult{
+		Info:          info,
+		RequestedName: name,
+		Renamed:       info.Name != name,
+	}
+	return
+}
+
+func (d *OneDrive) UploadOverwriteInfo(dirId string, name string, overwrite bool, content io.Reader) (info NodeInfo, err error) {
+	return d.UploadOverwriteInfoContext(context.Background(), dirId, name, overwrite, content)
+}
+
+// UploadOverwriteInfoContext is like UploadOverwriteContext but returns the
+// info of the uploaded file as reported by the server. If the size of
+// content can be determined without reading it (it has a Len method or is
+// an io.Seeker), uploads for which ShouldUseSession is true are sent with
+// an upload session.
+func (d *OneDrive) UploadOverwriteInfoContext(ctx context.Context, dirId string, name string, overwrite bool, content io.Reader) (info NodeInfo, err error) {
+	defer wrapOp(&err, "UploadOverwriteInfo", dirId+"/"+name)
+	if size, ok := contentSize(content); ok {
+		return d.uploadSized(ctx, dirId, name, content, size, overwrite)
+	}
+	return d.UploadWithOptionsContext(ctx, dirId, name, content, UploadOptions{Overwrite: overwrite})
+}
+
+// ConflictBehavior selects what an upload does when a file with the same
+// name exists.
+type ConflictBehavior int
+
+const (
+	// ConflictOverwrite replaces the existing file.
+	ConflictOverwrite ConflictBehavior = iota + 1
+	// ConflictRename lets the server pick a new name for the upload.
+	ConflictRename
+	// ConflictFail fails the upload with an error matching ErrConflict.
+	ConflictFail
+)
+
+var liveConflictValues = map[ConflictBehavior]string{
+	ConflictOverwrite: "true",
+	ConflictRename:    "ChooseNewName",
+	ConflictFail:      "false",
+}
+
+var graphConflictValues = map[ConflictBehavior]string{
+	ConflictOverwrite: "replace",
+	ConflictRename:    "rename",
+	ConflictFail:      "fail",
+}
+
+// UploadOptions controls UploadWithOptions.
+type UploadOptions struct {
+	// Overwrite replaces an existing file; otherwise the server picks a new
+	// name for the upload. It is ignored if Conflict is set.
+	Overwrite bool
+	// Conflict selects what happens if the file exists.
+	Conflict ConflictBehavior
+	// ContentType is sent as the Content-Type of the file. When empty it is
+	// derived from the file name's extension or, failing that, sniffed from
+	// the first 512 bytes of content.
+	ContentType string
+	// ModTime, if set, becomes the file's last modified time. Only
+	// BackendGraph supports it, setting fileSystemInfo after the upload;
+	// the Live API always uses the upload time and ModTime is ignored.
+	ModTime time.Time
+	// IfMatch, if set, makes the upload fail with an error matching
+	// ErrPreconditionFailed unless the existing file has this ETag.
+	IfMatch string
+	// Size is the length of content. When positive it is sent as the
+	// Content-Length; otherwise the body is sent with chunked encoding,
+	// unless content turns out to be empty.
+	Size int64
+}
+
+func (d *OneDrive) UploadWithOptions(dirId string, name string, content io.Reader, opts UploadOptions) (info NodeInfo, err error) {
+	return d.UploadWithOptionsContext(context.Background(), dirId, name, content, opts)
+}
+
+// UploadWithOptionsContext uploads content as dirId/name in a single
+// request. The content is streamed to the connection as it is read and is
+// never buffered in memory as a whole, so memory use does not depend on
+// its size.
+func (d *OneDrive) UploadWithOptionsContext(ctx context.Context, dirId string, name string, content io.Reader, opts UploadOptions) (info NodeInfo, err error) {
+	defer wrapOp(&err, "UploadWithOptions", dirId+"/"+name)
+	header, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+
+	contentType := opts.ContentType
+	if contentType == "" {
+		if contentType, content, err = detectContentType(name, content); err != nil {
+			return
+		}
+	}
+	header.Set("Content-Type", contentType)
+	if opts.IfMatch != "" {
+		header.Set("If-Match", opts.IfMatch)
+	}
+
+	conflict := opts.Conflict
+	if conflict == 0 {
+		conflict = ConflictRename
+		if opts.Overwrite {
+			conflict = ConflictOverwrite
+		}
+	}
+
 	params := url.Values{}
+	pth := d.childPath(dirId, name)
 
-	if overwrite {
-		params.Set("overwrite", "true")
+	if d.graph() {
+		pth += "/content"
+		params.Set("@microsoft.graph.conflictBehavior", graphConflictValues[conflict])
 	} else {
-		params.Set("overwrite", "ChooseNewName")
+		params.Set("overwrite", liveConflictValues[conflict])
 	}
 
-	resp := &struct {
-		Name string
-	}{}
+	// an empty body has to be sent with Content-Length: 0 rather than
+	// chunked for the server to create an empty file
+	if opts.Size <= 0 {
+		var empty bool
+		if empty, content, err = peekEmpty(content); err != nil {
+			return
+		}
+		if empty {
+			content = bytes.NewReader(nil)
+		}
+	}
 
+	target, finish := d.nodeTarget(&info, d.remoteDrive(dirId))
 	req := httpclient.RequestData{
-		Method:         "PUT",
-		Path:           "/" + dirId + "/files/" + name,
-		Params:         params,
-		Headers:        header,
-		ReqReader:      content,
-		ExpectedStatus: []int{200, 201},
-		RespValue:      resp,
-		RespEncoding:   httpclient.EncodingJSON,
+		Context:          ctx,
+		Method:           "PUT",
+		Path:             pth,
+		Params:           params,
+		Headers:          header,
+		ReqReader:        content,
+		ReqContentLength: opts.Size,
+		ExpectedStatus:   []int{200, 201},
+		RespValue:        target,
+		RespEncoding:     httpclient.EncodingJSON,
 	}
 
-	_, err = d.ApiClient.Request(&req)
+	_, err = d.request(ctx, d.ApiClient, &req)
+	if conflict == ConflictFail && isConflict(err) {
+		err = fmt.Errorf("%w %s", ErrConflict, name)
+	}
+	if isPreconditionFailed(err) {
+		err = fmt.Errorf("%w %s", ErrPreconditionFailed, name)
+	}
 	if err != nil {
 		return
 	}
+	finish()
 
-	newName = resp.Name
+	if !opts.ModTime.IsZero() && d.graph() {
+		info, err = d.setModTime(ctx, info, opts.ModTime)
+	}
 
 	return
 }
 
-func (d *OneDrive) ResolvePath(pth string) (id string, err error) {
-	root, err := d.RootInfo()
+func (d *OneDrive) UploadConflict(dirId string, name string, conflict ConflictBehavior, content io.Reader) (info NodeInfo, err error) {
+	return d.UploadConflictContext(context.Background(), dirId, name, conflict, content)
+}
+
+// UploadConflictContext uploads content as dirId/name, resolving a name
+// clash as selected by conflict.
+func (d *OneDrive) UploadConflictContext(ctx context.Context, dirId string, name string, conflict ConflictBehavior, content io.Reader) (info NodeInfo, err error) {
+	defer wrapOp(&err, "UploadConflict", dirId+"/"+name)
+	return d.UploadWithOptionsContext(ctx, dirId, name, content, UploadOptions{Conflict: conflict})
+}
+
+func (d *OneDrive) UploadWithProgress(dirId string, name string, overwrite bool, content io.Reader, total int64, progress ProgressFunc) (newName string, err error) {
+	return d.UploadWithProgressContext(context.Background(), dirId, name, overwrite, content, total, progress)
+}
+
+// UploadWithProgressContext is like UploadOverwriteContext but calls
+// progress as content is streamed. total is passed through to progress and
+// should be -1 if the size is not known.
+func (d *OneDrive) UploadWithProgressContext(ctx context.Context, dirId string, name string, overwrite bool, content io.Reader, total int64, progress ProgressFunc) (newName string, err error) {
+	defer wrapOp(&err, "UploadWithProgress", dirId+"/"+name)
+	opts := UploadOptions{
+		Overwrite: overwrite,
+		Size:      total,
+	}
+	info, err := d.UploadWithOptionsContext(ctx, dirId, name, newProgressReader(content, total, progress), opts)
 	if err != nil {
 		return
 	}
-	id = root.Id
 
-loopParts:
-	for _, part := range pathParts(pth) {
-		var files []NodeInfo
-		files, err = d.NodeFiles(id)
-		if err != nil {
-			return
+	newName = info.Name
+
+	return
+}
+
+func (d *OneDrive) CreateFolder(parentId string, name string) (info NodeInfo, err error) {
+	return d.CreateFolderContext(context.Background(), parentId, name)
+}
+
+// CreateFolderContext creates a folder called name inside parentId and
+// returns it. If a node with that name already exists the existing node is
+// left untouched and the returned error matches ErrConflict.
+func (d *OneDrive) CreateFolderContext(ctx context.Context, parentId string, name string) (info NodeInfo, err error) {
+	defer wrapOp(&err, "CreateFolder", parentId+"/"+name)
+	pth := d.itemPath(parentId)
+	var body interface{} = map[string]string{
+		"name": name,
+	}
+	if d.graph() {
+		pth = d.childrenPath(parentId)
+		body = map[string]interface{}{
+			"name":                              name,
+			"folder":                            struct{}{},
+			"@microsoft.graph.conflictBehavior": "fail",
 		}
-		name := strings.ToLower(part)
-		for _, file := range files {
-			if strings.ToLower(file.Name) == name {
-				id = file.Id
-				continue loopParts
-			}
+	}
+
+	info, err = d.nodeRequest(ctx, "POST", pth, d.remoteDrive(parentId), nil, body, 200, 201)
+	if isConflict(err) {
+		err = fmt.Errorf("%w %s", ErrConflict, name)
+	}
+	return
+}
+
+func (d *OneDrive) Rename(id string, newName string) (info NodeInfo, err error) {
+	return d.RenameContext(context.Background(), id, newName)
+}
+
+// RenameContext renames the node and returns its updated info. If a node
+// called newName already exists in the same folder the returned error
+// matches ErrConflict.
+func (d *OneDrive) RenameContext(ctx context.Context, id string, newName string) (info NodeInfo, err error) {
+	defer wrapOp(&err, "Rename", id)
+	method := "PUT"
+	if d.graph() {
+		method = "PATCH"
+	}
+
+	body := map[string]string{
+		"name": newName,
+	}
+	info, err = d.nodeRequest(ctx, method, d.itemPath(id), d.remoteDrive(id), nil, body, 200)
+	if isConflict(err) {
+		err = fmt.Errorf("%w %s", ErrConflict, newName)
+	}
+	if err == nil {
+		d.invalidate(id)
+	}
+	return
+}
+
+func (d *OneDrive) Move(id string, newParentId string) (info NodeInfo, err error) {
+	return d.MoveContext(context.Background(), id, newParentId)
+}
+
+// MoveContext moves the node into newParentId and returns its updated
+// info. Existing nodes are never overwritten; if the destination already
+// contains a node with the same name the returned error matches
+// ErrConflict.
+func (d *OneDrive) MoveContext(ctx context.Context, id string, newParentId string) (info NodeInfo, err error) {
+	defer wrapOp(&err, "Move", id)
+	method := "MOVE"
+	var body interface{} = map[string]string{
+		"destination": newParentId,
+	}
+	if d.graph() {
+		method = "PATCH"
+		body = graphParentReference(newParentId)
+	}
+
+	info, err = d.nodeRequest(ctx, method, d.itemPath(id), d.remoteDrive(id), nil, body, 200, 201)
+	if isConflict(err) {
+		err = fmt.Errorf("%w %s", ErrConflict, id)
+	}
+	if err == nil {
+		d.invalidate(id)
+	}
+	return
+}
+
+// DefaultCopyTimeout bounds how long Copy waits for an asynchronous copy to
+// finish. Use CopyContext for a different limit.
+var DefaultCopyTimeout = 30 * time.Minute
+
+func (d *OneDrive) Copy(id string, newParentId string) (info NodeInfo, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultCopyTimeout)
+	defer cancel()
+	return d.CopyContext(ctx, id, newParentId)
+}
+
+// CopyContext copies the node into newParentId and returns the info of the
+// copy. Large copies are performed asynchronously by the server; in that
+// case the status URL is polled until the copy completes, fails or ctx is
+// done.
+func (d *OneDrive) CopyContext(ctx context.Context, id string, newParentId string) (info NodeInfo, err error) {
+	defer wrapOp(&err, "Copy", id)
+	return d.CopyWithProgressContext(ctx, id, newParentId, nil)
+}
+
+func (d *OneDrive) CopyWithProgress(id string, newParentId string, progress OperationProgress) (info NodeInfo, err error) {
+	return d.CopyWithProgressContext(context.Background(), id, newParentId, progress)
+}
+
+// CopyWithProgressContext is like CopyContext but calls progress while an
+// asynchronous copy is polled.
+func (d *OneDrive) CopyWithProgressContext(ctx context.Context, id string, newParentId string, progress OperationProgress) (info NodeInfo, err error) {
+	defer wrapOp(&err, "CopyWithProgress", id)
+	header, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+
+	method, pth := "COPY", d.itemPath(id)
+	var body interface{} = map[string]string{
+		"destination": newParentId,
+	}
+	if d.graph() {
+		method, pth = "POST", d.itemPath(id)+"/copy"
+		body = graphParentReference(newParentId)
+	}
+
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         method,
+		Path:           pth,
+		Headers:        header,
+		ReqEncoding:    httpclient.EncodingJSON,
+		ReqValue:       body,
+		ExpectedStatus: []int{200, 201, 202},
+	}
+	res, err := d.request(ctx, d.ApiClient, req)
+	if err != nil {
+		if isConflict(err) {
+			err = fmt.Errorf("%w %s", ErrConflict, id)
+		}
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusAccepted {
+		target, finish := d.nodeTarget(&info, d.remoteDrive(newParentId))
+		if err = json.NewDecoder(res.Body).Decode(target); err == nil {
+			finish()
 		}
-		return "", fmt.Errorf("Not found %s", part)
+		return
+	}
+
+	statusUrl := res.Header.Get("Location")
+	if statusUrl == "" {
+		err = fmt.Errorf("Copy of %s accepted without a status URL", id)
+		return
+	}
+
+	result, err := d.pollOperation(ctx, statusUrl, progress)
+	if err != nil {
+		return
+	}
+
+	var status operationStatus
+	if err = json.Unmarshal(result, &status); err != nil {
+		return
+	}
+	resourceId := status.ResourceId
+	if resourceId == "" {
+		resourceId = status.Id
+	}
+	if driveId := d.remoteDrive(newParentId); driveId != "" {
+		resourceId = remoteId(driveId, resourceId)
+	}
+	return d.NodeInfoContext(ctx, resourceId)
+}
+
+func (d *OneDrive) Delete(id string) (err error) {
+	return d.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext deletes the node with the given id. If the node does not
+// exist the returned error matches ErrNotFound. Deletes are soft: the node
+// goes to the recycle bin, from where it can be restored in the OneDrive
+// web UI or, with BackendGraph, with Restore.
+func (d *OneDrive) DeleteContext(ctx context.Context, id string) (err error) {
+	defer wrapOp(&err, "Delete", id)
+	header, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         "DELETE",
+		Path:           d.itemPath(id),
+		Headers:        header,
+		ExpectedStatus: []int{200, 204},
+		RespConsume:    true,
+	}
+	_, err = d.request(ctx, d.ApiClient, req)
+	if isStatus(err, http.StatusNotFound) {
+		err = &NodeNotFoundError{Id: id}
+	}
+	if err == nil || IsNotFound(err) {
+		d.invalidate(id)
 	}
 	return
 }
 
-func pathParts(pth string) []string {
-	pth = path.Clean("/" + pth)
-	parts := make([]string, 0)
-	for pth != "/" {
-		var name string
-		pth, name = path.Split(pth)
-		pth = path.Clean(pth)
-		parts = append(parts, name)
+func (d *OneDrive) DeletePath(pth string) (err error) {
+	return d.DeletePathContext(context.Background(), pth)
+}
+
+func (d *OneDrive) DeletePathContext(ctx context.Context, pth string) (err error) {
+	defer wrapOp(&err, "DeletePath", pth)
+	id, err := d.ResolvePathContext(ctx, pth)
+	if err != nil {
+		return
 	}
+	return d.DeleteContext(ctx, id)
+}
 
-	//in-place reverse
-	l := len(parts) - 1
-	h := len(parts) / 2
-	for i := 0; i < h; i++ {
-		t := parts[i]
-		ii := l - i
-		parts[i] = parts[ii]
-		parts[ii] = t
+func (d *OneDrive) DeleteRecursive(id string) (err error) {
+	return d.DeleteRecursiveContext(context.Background(), id)
+}
+
+// DeleteRecursiveContext deletes the node and, if it is a folder, its
+// contents. The server normally deletes folders with their contents in one
+// request; only if it refuses are the children deleted first, depth-first
+// and up to d.Concurrency at a time. Failures are collected in a
+// *BatchError keyed by node id. A node that is already gone is not an
+// error.
+func (d *OneDrive) DeleteRecursiveContext(ctx context.Context, id string) (err error) {
+	defer wrapOp(&err, "DeleteRecursive", id)
+	if err = d.DeleteContext(ctx, id); err == nil || IsNotFound(err) || ctx.Err() != nil {
+		if IsNotFound(err) {
+			err = nil
+		}
+		return
+	}
+
+	files, lerr := d.NodeFilesContext(ctx, id)
+	if lerr != nil {
+		// not a folder, or not listable: report the delete failure
+		return
+	}
+
+	errs := make([]error, len(files))
+	d.forEach(ctx, len(files), func(i int) {
+		if files[i].IsDir() {
+			errs[i] = d.DeleteRecursiveContext(ctx, files[i].Id)
+		} else if errs[i] = d.DeleteContext(ctx, files[i].Id); IsNotFound(errs[i]) {
+			errs[i] = nil
+		}
+	})
+
+	batchErr := &BatchError{Errors: make(map[string]error)}
+	for i, file := range files {
+		if errs[i] == nil {
+			continue
+		}
+		if nested, ok := errs[i].(*BatchError); ok {
+			for key, nerr := range nested.Errors {
+				batchErr.Errors[key] = nerr
+			}
+		} else {
+			batchErr.Errors[file.Id] = errs[i]
+		}
+	}
+	if len(batchErr.Errors) > 0 {
+		return batchErr
 	}
-	return parts
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	if err = d.DeleteContext(ctx, id); IsNotFound(err) {
+		err = nil
+	}
+	return
 }
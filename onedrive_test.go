@@ -0,0 +1,425 @@
+package onedriveclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/koofr/go-ioutils"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a Live API client talking to handler.
+func newTestClient(t *testing.T, handler http.Handler) *OneDrive {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	auth := OneDriveAuth{
+		AccessToken: "token",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	d, err := NewOneDriveClientWithBaseURL(auth, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// newGraphTestClient returns a BackendGraph client talking to handler.
+func newGraphTestClient(t *testing.T, handler http.Handler) *OneDrive {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	auth := OneDriveAuth{
+		AccessToken: "token",
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	d := NewOneDriveClientWithBackend(auth, BackendGraph)
+	var err error
+	if d.ApiClient.BaseURL, err = url.Parse(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestNodeFilesPaging(t *testing.T) {
+	var srvURL string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/folder.1/files", func(w http.ResponseWriter, r *http.Request) {
+		// three pages of two files, addressed by paging link or offset
+		page := 1
+		if v := r.URL.Query().Get("page"); v != "" {
+			fmt.Sscan(v, &page)
+		}
+		if v := r.URL.Query().Get("offset"); v != "" {
+			var offset int
+			fmt.Sscan(v, &offset)
+			page = offset/2 + 1
+		}
+
+		var files NodeFiles
+		for i := 0; i < 2; i++ {
+			id := fmt.Sprintf("file.%d", (page-1)*2+i)
+			files.Data = append(files.Data, NodeInfo{Id: id, Name: id, Type: "file"})
+		}
+		if page < 3 {
+			files.Paging.Next = fmt.Sprintf("%s/folder.1/files?page=%d", srvURL, page+1)
+		}
+		json.NewEncoder(w).Encode(files)
+	})
+	d := newTestClient(t, mux)
+	srvURL = d.ApiClient.BaseURL.String()
+
+	files, err := d.NodeFiles("folder.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 6 {
+		t.Fatalf("got %d files, want 6", len(files))
+	}
+	for i, file := range files {
+		if want := fmt.Sprintf("file.%d", i); file.Id != want {
+			t.Errorf("files[%d].Id = %s, want %s", i, file.Id, want)
+		}
+	}
+
+	page, hasMore, err := d.NodeFilesPage("folder.1", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || !hasMore {
+		t.Errorf("first page: got %d files, hasMore %v; want 2, true", len(page), hasMore)
+	}
+
+	page, hasMore, err = d.NodeFilesPage("folder.1", 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || hasMore {
+		t.Errorf("last page: got %d files, hasMore %v; want 2, false", len(page), hasMore)
+	}
+}
+
+func TestUploadOverwriteEmpty(t *testing.T) {
+	tests := []struct {
+		name    string
+		content io.Reader
+	}{
+		{"Len", strings.NewReader("")},
+		{"no Len", struct{ io.Reader }{strings.NewReader("")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "PUT" || r.URL.Path != "/folder.1/files/empty.txt" {
+					t.Errorf("got %s %s, want PUT /folder.1/files/empty.txt", r.Method, r.URL.Path)
+				}
+				if got := r.Header.Get("Content-Length"); got != "0" {
+					t.Errorf("Content-Length = %q, want 0", got)
+				}
+				if len(r.TransferEncoding) > 0 {
+					t.Errorf("Transfer-Encoding = %v, want none", r.TransferEncoding)
+				}
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(NodeInfo{Id: "file.1", Name: "empty.txt", Type: "file"})
+			}))
+
+			if _, err := d.UploadOverwrite("folder.1", "empty.txt", true, tt.content); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestDownloadRedirectRange(t *testing.T) {
+	var srvURL string
+	var final bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/file.1", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(NodeInfo{Id: "file.1", Name: "a.txt", Type: "file", Source: srvURL + "/redirect"})
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/final", http.StatusFound)
+	})
+	mux.HandleFunc("/final", func(w http.ResponseWriter, r *http.Request) {
+		final = true
+		if got := r.Header.Get("Range"); got != "bytes=0-4" {
+			t.Errorf("Range = %q, want bytes=0-4", got)
+		}
+		w.Header().Set("Content-Range", "bytes 0-4/11")
+		w.WriteHeader(http.StatusPartialContent)
+		io.WriteString(w, "hello")
+	})
+	d := newTestClient(t, mux)
+	srvURL = d.ApiClient.BaseURL.String()
+
+	_, content, err := d.Download("file.1", &ioutils.FileSpan{Start: 0, End: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Close()
+
+	data, err := io.ReadAll(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !final {
+		t.Fatal("redirect was not followed")
+	}
+	if string(data) != "hello" {
+		t.Errorf("content = %q, want hello", data)
+	}
+}
+
+func TestNodeFilesIterError(t *testing.T) {
+	d := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	entries, errc := d.NodeFilesIter("folder.1")
+	for range entries {
+	}
+	err := <-errc
+
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.Op != "NodeFilesIter" || opErr.Arg != "folder.1" {
+		t.Fatalf("err = %v, want an OpError for NodeFilesIter folder.1", err)
+	}
+}
+
+func TestOpErrorNamesCalledMethod(t *testing.T) {
+	d := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	tests := []struct {
+		op   string
+		call func() error
+	}{
+		{"Download", func() error { _, _, err := d.Download("file.1", nil); return err }},
+		{"RootInfo", func() error { _, err := d.RootInfo(); return err }},
+		{"Copy", func() error { _, err := d.Copy("file.1", "folder.1"); return err }},
+		{"Upload", func() error { return d.Upload("folder.1", "a.txt", strings.NewReader("a")) }},
+		{"UploadOverwrite", func() error {
+			_, err := d.UploadOverwrite("folder.1", "a.txt", true, strings.NewReader("a"))
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		err := tt.call()
+		var opErr *OpError
+		if !errors.As(err, &opErr) || opErr.Op != tt.op {
+			t.Errorf("%s: err = %v, want an OpError for %s", tt.op, err, tt.op)
+			continue
+		}
+		if errors.As(opErr.Err, new(*OpError)) {
+			t.Errorf("%s: err = %v, want a single OpError", tt.op, err)
+		}
+	}
+}
+
+func TestRequestRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		calls      int
+	}{
+		{"throttled with Retry-After", http.StatusTooManyRequests, "0", 2},
+		{"unavailable", http.StatusServiceUnavailable, "", 2},
+		{"not retryable", http.StatusBadRequest, "", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			d := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.status)
+					return
+				}
+				json.NewEncoder(w).Encode(NodeInfo{Id: "file.1", Name: "a.txt", Type: "file"})
+			}))
+			d.BaseBackoff = time.Millisecond
+
+			_, err := d.NodeInfo("file.1")
+			if (err == nil) != (tt.calls == 2) {
+				t.Errorf("err = %v", err)
+			}
+			if calls != tt.calls {
+				t.Errorf("got %d requests, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+// tokenHandler answers token refreshes with the access token "new",
+// counting them in refreshes.
+func tokenHandler(t *testing.T, refreshes *int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*refreshes++
+		if got := r.FormValue("grant_type"); got != "refresh_token" {
+			t.Errorf("grant_type = %q, want refresh_token", got)
+		}
+		if got := r.FormValue("refresh_token"); got != "refresh" {
+			t.Errorf("refresh_token = %q, want refresh", got)
+		}
+		fmt.Fprint(w, `{"access_token":"new","refresh_token":"refresh","expires_in":3600}`)
+	}
+}
+
+func TestRefreshTokenURLAndScopes(t *testing.T) {
+	var refreshes int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.FormValue("scope"); got != "files.readwrite offline_access" {
+			t.Errorf("scope = %q, want files.readwrite offline_access", got)
+		}
+		tokenHandler(t, &refreshes)(w, r)
+	})
+	mux.HandleFunc("/file.1", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer new" {
+			t.Errorf("Authorization = %q, want Bearer new", got)
+		}
+		json.NewEncoder(w).Encode(NodeInfo{Id: "file.1", Name: "a.txt", Type: "file"})
+	})
+	d := newTestClient(t, mux)
+	d.Auth.ExpiresAt = time.Time{}
+	d.Auth.RefreshToken = "refresh"
+	d.Auth.TokenURL = d.ApiClient.BaseURL.String() + "/oauth/token"
+	d.Auth.Scopes = []string{"files.readwrite", "offline_access"}
+
+	if _, err := d.NodeInfo("file.1"); err != nil {
+		t.Fatal(err)
+	}
+	if refreshes != 1 {
+		t.Errorf("got %d refreshes, want 1", refreshes)
+	}
+}
+
+func TestRequestRefreshesOn401(t *testing.T) {
+	tests := []struct {
+		name    string
+		revoked bool
+	}{
+		{"accepted after refresh", false},
+		{"rejected after refresh", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var refreshes, calls int
+			mux := http.NewServeMux()
+			mux.HandleFunc("/oauth/token", tokenHandler(t, &refreshes))
+			mux.HandleFunc("/file.1", func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if tt.revoked || r.Header.Get("Authorization") != "Bearer new" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				json.NewEncoder(w).Encode(NodeInfo{Id: "file.1", Name: "a.txt", Type: "file"})
+			})
+			d := newTestClient(t, mux)
+			d.Auth.RefreshToken = "refresh"
+			d.Auth.TokenURL = d.ApiClient.BaseURL.String() + "/oauth/token"
+
+			_, err := d.NodeInfo("file.1")
+			if (err != nil) != tt.revoked {
+				t.Errorf("err = %v", err)
+			}
+			if refreshes != 1 || calls != 2 {
+				t.Errorf("got %d refreshes and %d requests, want 1 and 2", refreshes, calls)
+			}
+		})
+	}
+}
+
+func TestDownloadRanges(t *testing.T) {
+	const content = "abcdefghij"
+	tests := []struct {
+		name      string
+		multipart bool
+	}{
+		{"multipart", true},
+		{"no range support", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var srvURL string
+			mux := http.NewServeMux()
+			mux.HandleFunc("/file.1", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(NodeInfo{Id: "file.1", Name: "a.txt", Type: "file", Source: srvURL + "/content"})
+			})
+			mux.HandleFunc("/content", func(w http.ResponseWriter, r *http.Request) {
+				if !tt.multipart {
+					io.WriteString(w, content)
+					return
+				}
+				if got := r.Header.Get("Range"); got != "bytes=0-1,4-5" {
+					t.Errorf("Range = %q, want bytes=0-1,4-5", got)
+				}
+				mw := multipart.NewWriter(w)
+				w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+				w.WriteHeader(http.StatusPartialContent)
+				for _, span := range []ioutils.FileSpan{{Start: 0, End: 1}, {Start: 4, End: 5}} {
+					part, _ := mw.CreatePart(textproto.MIMEHeader{
+						"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", span.Start, span.End, len(content))},
+					})
+					io.WriteString(part, content[span.Start:span.End+1])
+				}
+				mw.Close()
+			})
+			d := newTestClient(t, mux)
+			srvURL = d.ApiClient.BaseURL.String()
+
+			_, contents, err := d.DownloadRanges("file.1", []ioutils.FileSpan{{Start: 0, End: 1}, {Start: 4, End: 5}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, c := range contents {
+				data, _ := io.ReadAll(c)
+				got = append(got, string(data))
+			}
+			if strings.Join(got, ",") != "ab,ef" {
+				t.Errorf("contents = %q, want [ab ef]", got)
+			}
+		})
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	d := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"error":{"code":"accessDenied","message":"Access denied"}}`)
+	}))
+
+	_, err := d.NodeInfo("file.1")
+	apiErr, ok := AsAPIError(err)
+	if !ok {
+		t.Fatalf("err = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusForbidden || apiErr.Code != "accessDenied" || apiErr.Message != "Access denied" {
+		t.Errorf("got %d %s %q, want 403 accessDenied \"Access denied\"", apiErr.StatusCode, apiErr.Code, apiErr.Message)
+	}
+	if !isStatus(err, http.StatusForbidden) {
+		t.Errorf("err = %v, want the status to stay visible", err)
+	}
+}
@@ -0,0 +1,382 @@
+// Package onedrivefake provides an in-memory implementation of
+// onedriveclient.Client for tests.
+package onedrivefake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/koofr/go-ioutils"
+	"github.com/niltonkummer/go-onedriveclient"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const RootId = "root"
+
+type node struct {
+	info    onedriveclient.NodeInfo
+	content []byte
+}
+
+// Client is an in-memory drive. Names are compared case-insensitively like
+// the server does. Operations fail with ctx.Err() once their context is
+// done, so that cancellation can be tested. It is safe for concurrent use.
+type Client struct {
+	mu     sync.Mutex
+	nodes  map[string]*node
+	nextId int
+}
+
+var _ onedriveclient.Client = (*Client)(nil)
+
+// New returns a Client with an empty root folder.
+func New() *Client {
+	c := &Client{nodes: make(map[string]*node)}
+	c.nodes[RootId] = &node{info: onedriveclient.NodeInfo{
+		Id:   RootId,
+		Name: "root",
+		Type: "folder",
+	}}
+	return c
+}
+
+func (c *Client) get(id string) (n *node, err error) {
+	n, ok := c.nodes[id]
+	if !ok {
+		err = &onedriveclient.NodeNotFoundError{Id: id}
+	}
+	return
+}
+
+func (c *Client) folder(id string) (n *node, err error) {
+	if n, err = c.get(id); err == nil && !n.info.IsDir() {
+		err = fmt.Errorf("%w %s", onedriveclient.ErrNotFolder, id)
+	}
+	return
+}
+
+func (c *Client) child(parentId string, name string) *node {
+	for _, n := range c.nodes {
+		if n.info.ParentId == parentId && onedriveclient.SameName(n.info.Name, name) {
+			return n
+		}
+	}
+	return nil
+}
+
+func (c *Client) add(parentId string, name string, typ string, content []byte) *node {
+	c.nextId++
+	now := time.Now().UTC()
+	n := &node{
+		info: onedriveclient.NodeInfo{
+			Id:          "node." + strconv.Itoa(c.nextId),
+			Name:        name,
+			ParentId:    parentId,
+			Size:        int64(len(content)),
+			Type:        typ,
+			CreatedTime: now.Format(time.RFC3339),
+			UpdatedTime: now.Format(time.RFC3339),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		},
+		content: content,
+	}
+	c.nodes[n.info.Id] = n
+	return n
+}
+
+// freeName returns name, or name with a number appended if it is taken.
+func (c *Client) freeName(parentId string, name string) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; c.child(parentId, name) != nil; i++ {
+		name = fmt.Sprintf("%s %d%s", base, i, ext)
+	}
+	return name
+}
+
+func (c *Client) NodeInfo(id string) (info onedriveclient.NodeInfo, err error) {
+	return c.NodeInfoContext(context.Background(), id)
+}
+
+func (c *Client) NodeInfoContext(ctx context.Context, id string) (info onedriveclient.NodeInfo, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, err := c.get(id)
+	if err != nil {
+		return
+	}
+	return n.info, nil
+}
+
+func (c *Client) RootInfo() (info onedriveclient.NodeInfo, err error) {
+	return c.RootInfoContext(context.Background())
+}
+
+func (c *Client) RootInfoContext(ctx context.Context) (info onedriveclient.NodeInfo, err error) {
+	return c.NodeInfoContext(ctx, RootId)
+}
+
+func (c *Client) NodeFiles(id string) (files []onedriveclient.NodeInfo, err error) {
+	return c.NodeFilesContext(context.Background(), id)
+}
+
+// NodeFilesContext lists the children of the folder sorted by name.
+func (c *Client) NodeFilesContext(ctx context.Context, id string) (files []onedriveclient.NodeInfo, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err = c.folder(id); err != nil {
+		return
+	}
+	files = make([]onedriveclient.NodeInfo, 0)
+	for _, n := range c.nodes {
+		if n.info.ParentId == id {
+			files = append(files, n.info)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return
+}
+
+func (c *Client) ResolvePath(pth string) (id string, err error) {
+	return c.ResolvePathContext(context.Background(), pth)
+}
+
+func (c *Client) ResolvePathContext(ctx context.Context, pth string) (id string, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id = RootId
+	walked := ""
+	for _, part := range strings.Split(path.Clean("/"+pth), "/") {
+		if part == "" {
+			continue
+		}
+		walked += "/" + part
+		if _, err = c.folder(id); err != nil {
+			return "", fmt.Errorf("%w %s", onedriveclient.ErrNotFolder, path.Dir(walked))
+		}
+		n := c.child(id, part)
+		if n == nil {
+			return "", &onedriveclient.NodeNotFoundError{Path: walked}
+		}
+		id = n.info.Id
+	}
+	return
+}
+
+func (c *Client) Download(id string, span *ioutils.FileSpan) (info onedriveclient.NodeInfo, content io.ReadCloser, err error) {
+	return c.DownloadContext(context.Background(), id, span)
+}
+
+func (c *Client) DownloadContext(ctx context.Context, id string, span *ioutils.FileSpan) (info onedriveclient.NodeInfo, content io.ReadCloser, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, err := c.get(id)
+	if err != nil {
+		return
+	}
+	if n.info.IsDir() {
+		err = fmt.Errorf("Cannot download %s", id)
+		return
+	}
+
+	data := n.content
+	if span != nil {
+		if span.Start < 0 || span.Start > span.End || span.End >= int64(len(data)) {
+			err = fmt.Errorf("Invalid range %d-%d of %s", span.Start, span.End, id)
+			return
+		}
+		data = data[span.Start : span.End+1]
+	}
+
+	info = n.info
+	info.Size = int64(len(data))
+	content = ioutil.NopCloser(bytes.NewReader(data))
+	return
+}
+
+func (c *Client) Upload(dirId string, name string, content io.Reader) (err error) {
+	return c.UploadContext(context.Background(), dirId, name, content)
+}
+
+func (c *Client) UploadContext(ctx context.Context, dirId string, name string, content io.Reader) (err error) {
+	_, err = c.UploadOverwriteContext(ctx, dirId, name, true, content)
+	return
+}
+
+func (c *Client) UploadOverwrite(dirId string, name string, overwrite bool, content io.Reader) (newName string, err error) {
+	return c.UploadOverwriteContext(context.Background(), dirId, name, overwrite, content)
+}
+
+// UploadOverwriteContext stores content as dirId/name. Unless overwrite is
+// set, an existing name gets a number appended like the server does.
+func (c *Client) UploadOverwriteContext(ctx context.Context, dirId string, name string, overwrite bool, content io.Reader) (newName string, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err = c.folder(dirId); err != nil {
+		return
+	}
+
+	if existing := c.child(dirId, name); existing != nil {
+		if overwrite && existing.info.IsFile() {
+			existing.content = data
+			existing.info.Size = int64(len(data))
+			existing.info.UpdatedAt = time.Now().UTC()
+			existing.info.UpdatedTime = existing.info.UpdatedAt.Format(time.RFC3339)
+			return existing.info.Name, nil
+		}
+		name = c.freeName(dirId, name)
+	}
+
+	return c.add(dirId, name, "file", data).info.Name, nil
+}
+
+func (c *Client) CreateFolder(parentId string, name string) (info onedriveclient.NodeInfo, err error) {
+	return c.CreateFolderContext(context.Background(), parentId, name)
+}
+
+// CreateFolderContext creates the folder. If the name is taken the
+// returned error matches onedriveclient.ErrConflict.
+func (c *Client) CreateFolderContext(ctx context.Context, parentId string, name string) (info onedriveclient.NodeInfo, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err = c.folder(parentId); err != nil {
+		return
+	}
+	if c.child(parentId, name) != nil {
+		err = fmt.Errorf("%w %s", onedriveclient.ErrConflict, name)
+		return
+	}
+	return c.add(parentId, name, "folder", nil).info, nil
+}
+
+func (c *Client) Rename(id string, newName string) (info onedriveclient.NodeInfo, err error) {
+	return c.RenameContext(context.Background(), id, newName)
+}
+
+func (c *Client) RenameContext(ctx context.Context, id string, newName string) (info onedriveclient.NodeInfo, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, err := c.get(id)
+	if err != nil {
+		return
+	}
+	if other := c.child(n.info.ParentId, newName); other != nil && other != n {
+		err = fmt.Errorf("%w %s", onedriveclient.ErrConflict, newName)
+		return
+	}
+	n.info.Name = newName
+	return n.info, nil
+}
+
+func (c *Client) Move(id string, newParentId string) (info onedriveclient.NodeInfo, err error) {
+	return c.MoveContext(context.Background(), id, newParentId)
+}
+
+func (c *Client) MoveContext(ctx context.Context, id string, newParentId string) (info onedriveclient.NodeInfo, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, err := c.get(id)
+	if err != nil {
+		return
+	}
+	if _, err = c.folder(newParentId); err != nil {
+		return
+	}
+	for p := newParentId; p != ""; p = c.nodes[p].info.ParentId {
+		if p == id {
+			err = fmt.Errorf("Cannot move %s into itself", id)
+			return
+		}
+	}
+	if c.child(newParentId, n.info.Name) != nil {
+		err = fmt.Errorf("%w %s", onedriveclient.ErrConflict, n.info.Name)
+		return
+	}
+	n.info.ParentId = newParentId
+	return n.info, nil
+}
+
+func (c *Client) Delete(id string) (err error) {
+	return c.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext deletes the node and everything below it.
+func (c *Client) DeleteContext(ctx context.Context, id string) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err = c.get(id); err != nil {
+		return
+	}
+	if id == RootId {
+		return fmt.Errorf("Cannot delete the root")
+	}
+	c.delete(id)
+	return
+}
+
+func (c *Client) delete(id string) {
+	for childId, n := range c.nodes {
+		if n.info.ParentId == id {
+			c.delete(childId)
+		}
+	}
+	delete(c.nodes, id)
+}
@@ -0,0 +1,91 @@
+package onedriveclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/koofr/go-httpclient"
+	"io/ioutil"
+	"time"
+)
+
+// OperationProgress is called with the percentage (0 to 100) of a
+// long-running operation such as a copy completed so far.
+type OperationProgress func(percent float64)
+
+const (
+	operationPollStart = 1 * time.Second
+	operationPollMax   = 10 * time.Second
+)
+
+// operationStatus is the status document of an asynchronous operation.
+// Once the operation is done the monitor URL may redirect to the resulting
+// item, in which case only Id is set.
+type operationStatus struct {
+	Status             string  `json:"status"`
+	PercentageComplete float64 `json:"percentageComplete"`
+	ResourceId         string  `json:"resourceId"`
+	Id                 string  `json:"id"`
+	Error              *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// pollOperation polls the status URL of an asynchronous operation, backing
+// off from 1 to 10 seconds between requests, until the operation completes
+// or fails, and returns the final status body. progress, if set, is called
+// with the completion percentage after every poll.
+func (d *OneDrive) pollOperation(ctx context.Context, statusUrl string, progress OperationProgress) (body []byte, err error) {
+	delay := operationPollStart
+
+	for {
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			err = ctx.Err()
+			return
+		case <-t.C:
+		}
+
+		req := &httpclient.RequestData{
+			Context:        ctx,
+			Method:         "GET",
+			FullURL:        statusUrl,
+			ExpectedStatus: []int{200, 202},
+		}
+		res, rerr := d.request(ctx, d.ContentClient, req)
+		if rerr != nil {
+			return nil, rerr
+		}
+		body, err = ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return
+		}
+
+		var status operationStatus
+		if err = json.Unmarshal(body, &status); err != nil {
+			return
+		}
+		if progress != nil && status.PercentageComplete > 0 {
+			progress(status.PercentageComplete)
+		}
+
+		switch {
+		case status.Status == "completed", status.Status == "" && status.Id != "":
+			return
+		case status.Status == "failed":
+			err = fmt.Errorf("Operation failed")
+			if status.Error != nil {
+				err = fmt.Errorf("Operation failed: %s: %s", status.Error.Code, status.Error.Message)
+			}
+			return
+		}
+
+		if delay < operationPollMax {
+			delay *= 2
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package onedriveclient
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"hash"
+)
+
+// quickXorHash implements the QuickXorHash algorithm OneDrive uses to hash
+// file content.
+type quickXorHash struct {
+	data        [3]uint64
+	shiftSoFar  int
+	lengthSoFar int64
+}
+
+const (
+	quickXorWidth = 160
+	quickXorShift = 11
+)
+
+func newQuickXorHash() hash.Hash {
+	return &quickXorHash{}
+}
+
+func (q *quickXorHash) Write(p []byte) (n int, err error) {
+	currentShift := q.shiftSoFar
+	vectorArrayIndex := currentShift / 64
+	vectorOffset := currentShift % 64
+	iterations := len(p)
+	if iterations > quickXorWidth {
+		iterations = quickXorWidth
+	}
+
+	for i := 0; i < iterations; i++ {
+		isLastCell := vectorArrayIndex == len(q.data)-1
+		bitsInVectorCell := 64
+		if isLastCell {
+			bitsInVectorCell = quickXorWidth % 64
+		}
+
+		if vectorOffset <= bitsInVectorCell-8 {
+			for j := i; j < len(p); j += quickXorWidth {
+				q.data[vectorArrayIndex] ^= uint64(p[j]) << uint(vectorOffset)
+			}
+		} else {
+			index1 := vectorArrayIndex
+			index2 := vectorArrayIndex + 1
+			if isLastCell {
+				index2 = 0
+			}
+			low := uint(bitsInVectorCell - vectorOffset)
+
+			var xoredByte byte
+			for j := i; j < len(p); j += quickXorWidth {
+				xoredByte ^= p[j]
+			}
+			q.data[index1] ^= uint64(xoredByte) << uint(vectorOffset)
+			q.data[index2] ^= uint64(xoredByte) >> low
+		}
+
+		vectorOffset += quickXorShift
+		for vectorOffset >= bitsInVectorCell {
+			if isLastCell {
+				vectorArrayIndex = 0
+			} else {
+				vectorArrayIndex++
+			}
+			vectorOffset -= bitsInVectorCell
+		}
+	}
+
+	q.shiftSoFar = (q.shiftSoFar + quickXorShift*(len(p)%quickXorWidth)) % quickXorWidth
+	q.lengthSoFar += int64(len(p))
+
+	return len(p), nil
+}
+
+func (q *quickXorHash) Sum(b []byte) []byte {
+	rgb := make([]byte, q.Size())
+	binary.LittleEndian.PutUint64(rgb[0:], q.data[0])
+	binary.LittleEndian.PutUint64(rgb[8:], q.data[1])
+	var last [8]byte
+	binary.LittleEndian.PutUint64(last[:], q.data[2])
+	copy(rgb[16:], last[:4])
+
+	var length [8]byte
+	binary.LittleEndian.PutUint64(length[:], uint64(q.lengthSoFar))
+	for i := 0; i < 8; i++ {
+		rgb[quickXorWidth/8-8+i] ^= length[i]
+	}
+
+	return append(b, rgb...)
+}
+
+func (q *quickXorHash) Reset() {
+	*q = quickXorHash{}
+}
+
+func (q *quickXorHash) Size() int {
+	return quickXorWidth / 8
+}
+
+func (q *quickXorHash) BlockSize() int {
+	return 64
+}
+
+// quickXorString returns the hash in the base64 form the API reports.
+func quickXorString(h hash.Hash) string {
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
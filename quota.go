@@ -0,0 +1,96 @@
+package onedriveclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/koofr/go-httpclient"
+	"net/http"
+	"strconv"
+)
+
+// ErrInsufficientQuota is returned by CheckQuota when there is not enough
+// space left.
+var ErrInsufficientQuota = errors.New("Insufficient quota")
+
+func (d *OneDrive) Quota() (quota QuotaInfo, err error) {
+	return d.QuotaContext(context.Background())
+}
+
+func (d *OneDrive) QuotaContext(ctx context.Context) (quota QuotaInfo, err error) {
+	defer wrapOp(&err, "Quota", "quota")
+	header, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+
+	if d.graph() {
+		return d.graphQuota(ctx, header)
+	}
+
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         "GET",
+		Path:           "/me/skydrive/quota",
+		Headers:        header,
+		ExpectedStatus: []int{200},
+		RespEncoding:   httpclient.EncodingJSON,
+		RespValue:      &quota,
+	}
+	_, err = d.request(ctx, d.ApiClient, req)
+	if err != nil {
+		return
+	}
+
+	quota.Used = quota.Total - quota.Remaining
+	return
+}
+
+func (d *OneDrive) graphQuota(ctx context.Context, header http.Header) (quota QuotaInfo, err error) {
+	resp := &struct {
+		Quota struct {
+			Total     int64 `json:"total"`
+			Used      int64 `json:"used"`
+			Remaining int64 `json:"remaining"`
+		} `json:"quota"`
+	}{}
+
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         "GET",
+		Path:           "/me/drive",
+		Headers:        header,
+		ExpectedStatus: []int{200},
+		RespEncoding:   httpclient.EncodingJSON,
+		RespValue:      resp,
+	}
+	_, err = d.request(ctx, d.ApiClient, req)
+	if err != nil {
+		return
+	}
+
+	quota = QuotaInfo{
+		Total:     resp.Quota.Total,
+		Used:      resp.Quota.Used,
+		Remaining: resp.Quota.Remaining,
+	}
+	return
+}
+
+func (d *OneDrive) CheckQuota(size int64) (err error) {
+	return d.CheckQuotaContext(context.Background(), size)
+}
+
+// CheckQuotaContext returns an error matching ErrInsufficientQuota if fewer
+// than size bytes are available. Use it before a large upload to fail fast.
+func (d *OneDrive) CheckQuotaContext(ctx context.Context, size int64) (err error) {
+	defer wrapOp(&err, "CheckQuota", strconv.FormatInt(size, 10))
+	quota, err := d.QuotaContext(ctx)
+	if err != nil {
+		return
+	}
+	if quota.Remaining < size {
+		err = fmt.Errorf("%w: need %d bytes, %d available", ErrInsufficientQuota, size, quota.Remaining)
+	}
+	return
+}
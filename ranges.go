@@ -0,0 +1,121 @@
+package onedriveclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/koofr/go-ioutils"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+func (d *OneDrive) DownloadRanges(id string, spans []ioutils.FileSpan) (info NodeInfo, contents []io.Reader, err error) {
+	return d.DownloadRangesContext(context.Background(), id, spans)
+}
+
+// DownloadRangesContext downloads several byte ranges of the node. spans
+// are inclusive like in Download. The ranges are requested at once with a
+// multipart/byteranges response; if the server does not support that, they
+// are fetched one request at a time. contents[i] yields the bytes of
+// spans[i]. The ranges are read into memory, so they should be small.
+func (d *OneDrive) DownloadRangesContext(ctx context.Context, id string, spans []ioutils.FileSpan) (info NodeInfo, contents []io.Reader, err error) {
+	defer wrapOp(&err, "DownloadRanges", id)
+	info, err = d.NodeInfoContext(ctx, id)
+	if err != nil || len(spans) == 0 {
+		return
+	}
+
+	rngs := make([]string, len(spans))
+	for i, span := range spans {
+		if span.Start < 0 || span.End < span.Start {
+			err = fmt.Errorf("Invalid range %d-%d", span.Start, span.End)
+			return
+		}
+		rngs[i] = fmt.Sprintf("%d-%d", span.Start, span.End)
+	}
+
+	if len(spans) > 1 {
+		res, rerr := d.downloadSource(ctx, info, "bytes="+strings.Join(rngs, ","))
+		if rerr != nil {
+			err = rerr
+			return
+		}
+		mediaType, params, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+		if res.StatusCode == http.StatusPartialContent && mediaType == "multipart/byteranges" {
+			contents, err = readByteRanges(multipart.NewReader(res.Body, params["boundary"]), spans)
+		}
+		res.Body.Close()
+		if contents != nil || err != nil {
+			return
+		}
+	}
+
+	// the server collapsed the ranges into a single response
+	contents = make([]io.Reader, len(spans))
+	for i, span := range spans {
+		var buf []byte
+		if buf, err = d.downloadSpan(ctx, info, span); err != nil {
+			return
+		}
+		contents[i] = bytes.NewReader(buf)
+	}
+	return
+}
+
+// readByteRanges reads the parts of a multipart/byteranges response. It
+// returns nil contents if the parts do not match spans, e.g. because the
+// server merged adjacent ranges.
+func readByteRanges(mr *multipart.Reader, spans []ioutils.FileSpan) (contents []io.Reader, err error) {
+	parts := make(map[string][]byte)
+	for {
+		part, perr := mr.NextPart()
+		if perr == io.EOF {
+			break
+		}
+		if perr != nil {
+			return nil, perr
+		}
+
+		var start, end int64
+		if _, serr := fmt.Sscanf(part.Header.Get("Content-Range"), "bytes %d-%d/", &start, &end); serr != nil {
+			return nil, nil
+		}
+		buf, rerr := ioutil.ReadAll(part)
+		if rerr != nil {
+			return nil, rerr
+		}
+		parts[fmt.Sprintf("%d-%d", start, end)] = buf
+	}
+
+	contents = make([]io.Reader, len(spans))
+	for i, span := range spans {
+		buf, ok := parts[fmt.Sprintf("%d-%d", span.Start, span.End)]
+		if !ok {
+			return nil, nil
+		}
+		contents[i] = bytes.NewReader(buf)
+	}
+	return
+}
+
+// downloadSpan reads a single range, skipping to it if the server sends the
+// whole content.
+func (d *OneDrive) downloadSpan(ctx context.Context, info NodeInfo, span ioutils.FileSpan) (buf []byte, err error) {
+	res, err := d.downloadSource(ctx, info, fmt.Sprintf("bytes=%d-%d", span.Start, span.End))
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	body := io.Reader(res.Body)
+	if res.StatusCode == http.StatusOK {
+		if _, err = io.CopyN(ioutil.Discard, body, span.Start); err != nil {
+			return
+		}
+	}
+	return ioutil.ReadAll(io.LimitReader(body, span.End-span.Start+1))
+}
@@ -0,0 +1,86 @@
+package onedriveclient
+
+import (
+	"github.com/koofr/go-httpclient"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitInfo is the throttling information the server sent with a
+// response. Limit and Remaining are -1 if the server did not send them.
+type RateLimitInfo struct {
+	// RetryAfter is how long the server asked the client to wait, zero if
+	// it did not ask.
+	RetryAfter time.Duration
+	Limit      int
+	Remaining  int
+	// Reset is when the current rate limit window ends, zero if unknown.
+	Reset time.Time
+	// At is when the response was received.
+	At time.Time
+}
+
+// parseRateLimit reads the Retry-After and RateLimit-* headers. ok is false
+// if none of them are present.
+func parseRateLimit(h http.Header, now time.Time) (info RateLimitInfo, ok bool) {
+	info = RateLimitInfo{Limit: -1, Remaining: -1, At: now}
+	info.RetryAfter, ok = retryAfter(h)
+
+	if v, err := strconv.Atoi(h.Get("RateLimit-Limit")); err == nil {
+		info.Limit = v
+		ok = true
+	}
+	if v, err := strconv.Atoi(h.Get("RateLimit-Remaining")); err == nil {
+		info.Remaining = v
+		ok = true
+	}
+	if v, err := strconv.Atoi(h.Get("RateLimit-Reset")); err == nil {
+		info.Reset = now.Add(time.Duration(v) * time.Second)
+		ok = true
+	}
+	return
+}
+
+// RateLimitFromError returns the throttling information of a failed
+// request, e.g. the Retry-After of a 429 that was not retried.
+func RateLimitFromError(err error) (info RateLimitInfo, ok bool) {
+	ise, isIse := invalidStatus(err)
+	if !isIse {
+		return
+	}
+	return parseRateLimit(ise.Headers, time.Now())
+}
+
+type rateLimitState struct {
+	mu   sync.Mutex
+	info RateLimitInfo
+	ok   bool
+}
+
+func (s *rateLimitState) record(res *http.Response, err error) {
+	var h http.Header
+	if res != nil {
+		h = res.Header
+	} else if ise, ok := httpclient.IsInvalidStatusError(err); ok {
+		h = ise.Headers
+	}
+	info, ok := parseRateLimit(h, time.Now())
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	s.info, s.ok = info, true
+	s.mu.Unlock()
+}
+
+// RateLimit returns the throttling information of the most recent response
+// that carried any. ok is false if no response did yet. A sync tool can use
+// it to pause all workers until RetryAfter has passed.
+func (d *OneDrive) RateLimit() (info RateLimitInfo, ok bool) {
+	d.rateLimit.mu.Lock()
+	defer d.rateLimit.mu.Unlock()
+	return d.rateLimit.info, d.rateLimit.ok
+}
@@ -0,0 +1,179 @@
+package onedriveclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"sync"
+)
+
+// contextReadCloser closes the underlying reader as soon as ctx is done and
+// reports ctx.Err() from subsequent reads.
+type contextReadCloser struct {
+	ctx       context.Context
+	rc        io.ReadCloser
+	stop      func() bool
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newContextReadCloser(ctx context.Context, rc io.ReadCloser) *contextReadCloser {
+	r := &contextReadCloser{ctx: ctx, rc: rc}
+	r.stop = context.AfterFunc(ctx, func() {
+		r.close()
+	})
+	return r
+}
+
+func (r *contextReadCloser) Read(p []byte) (n int, err error) {
+	if err = r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err = r.rc.Read(p)
+	if err != nil && r.ctx.Err() != nil {
+		err = r.ctx.Err()
+	}
+	return
+}
+
+func (r *contextReadCloser) Close() error {
+	r.stop()
+	return r.close()
+}
+
+func (r *contextReadCloser) close() error {
+	r.closeOnce.Do(func() {
+		r.closeErr = r.rc.Close()
+	})
+	return r.closeErr
+}
+
+// ProgressFunc is called as content is transferred with the number of
+// bytes transferred so far and the total, which is -1 when unknown.
+type ProgressFunc func(bytesSent int64, totalBytes int64)
+
+type progressReader struct {
+	r        io.Reader
+	n        int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (r *progressReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	if n > 0 {
+		r.n += int64(n)
+		r.progress(r.n, r.total)
+	}
+	return
+}
+
+// progressReadSeeker keeps the count right when the request is retried and
+// the content rewound.
+type progressReadSeeker struct {
+	progressReader
+}
+
+func (r *progressReadSeeker) Seek(offset int64, whence int) (pos int64, err error) {
+	pos, err = r.r.(io.Seeker).Seek(offset, whence)
+	if err == nil {
+		r.n = pos
+	}
+	return
+}
+
+// newProgressReader wraps r so that progress is reported as it is read. The
+// result is an io.Seeker only if r is.
+func newProgressReader(r io.Reader, total int64, progress ProgressFunc) io.Reader {
+	if progress == nil {
+		return r
+	}
+	pr := progressReader{r: r, total: total, progress: progress}
+	if _, ok := r.(io.Seeker); ok {
+		return &progressReadSeeker{pr}
+	}
+	return &pr
+}
+
+// detectContentType guesses the content type from the file name's
+// extension or, if that is unknown, from the first 512 bytes of content.
+// The returned reader yields the whole content and is seekable if content
+// is.
+func detectContentType(name string, content io.Reader) (contentType string, r io.Reader, err error) {
+	if contentType = mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType, content, nil
+	}
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(content, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+	buf = buf[:n]
+	contentType = http.DetectContentType(buf)
+
+	if s, ok := content.(io.Seeker); ok {
+		if _, err = s.Seek(-int64(n), io.SeekCurrent); err != nil {
+			return
+		}
+		return contentType, content, nil
+	}
+
+	return contentType, io.MultiReader(bytes.NewReader(buf), content), nil
+}
+
+// peekEmpty reports whether content has no data. The returned reader yields
+// the whole content and is seekable if content is.
+func peekEmpty(content io.Reader) (empty bool, r io.Reader, err error) {
+	if l, ok := content.(interface{ Len() int }); ok {
+		return l.Len() == 0, content, nil
+	}
+
+	buf := make([]byte, 1)
+	n, err := io.ReadFull(content, buf)
+	if err == io.EOF {
+		return true, content, nil
+	}
+	if err != nil {
+		return
+	}
+
+	if s, ok := content.(io.Seeker); ok {
+		if _, err = s.Seek(-int64(n), io.SeekCurrent); err != nil {
+			return
+		}
+		return false, content, nil
+	}
+
+	return false, io.MultiReader(bytes.NewReader(buf), content), nil
+}
+
+// contentSize returns the number of bytes left in content if that is known
+// without reading it.
+func contentSize(content io.Reader) (size int64, ok bool) {
+	if l, isLen := content.(interface{ Len() int }); isLen {
+		return int64(l.Len()), true
+	}
+	s, isSeeker := content.(io.Seeker)
+	if !isSeeker {
+		return
+	}
+	cur, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+	if _, err = s.Seek(cur, io.SeekStart); err != nil {
+		return
+	}
+	return end - cur, true
+}
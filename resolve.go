@@ -0,0 +1,348 @@
+package onedriveclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/text/unicode/norm"
+	"net/http"
+	"path"
+	"strings"
+)
+
+func (d *OneDrive) ResolvePath(pth string) (id string, err error) {
+	return d.ResolvePathContext(context.Background(), pth)
+}
+
+func (d *OneDrive) ResolvePathContext(ctx context.Context, pth string) (id string, err error) {
+	defer wrapOp(&err, "ResolvePath", pth)
+	info, err := d.resolvePath(ctx, pth, false)
+	if err != nil {
+		return
+	}
+	id = info.Id
+	return
+}
+
+func (d *OneDrive) ResolvePathInfo(pth string) (info NodeInfo, err error) {
+	return d.ResolvePathInfoContext(context.Background(), pth)
+}
+
+// ResolvePathInfoContext resolves the path and returns the info of its last
+// component as found in the parent's listing, saving a NodeInfo round-trip.
+func (d *OneDrive) ResolvePathInfoContext(ctx context.Context, pth string) (info NodeInfo, err error) {
+	defer wrapOp(&err, "ResolvePathInfo", pth)
+	return d.resolvePath(ctx, pth, true)
+}
+
+// resolvePath walks the path from the root. Unless fullInfo is set, the
+// returned info may only have its Id filled in when it came from the
+// path cache.
+//
+// Graph addresses items by path, so there the path is first looked up in a
+// single request. If that fails with 400 or 404 the path is walked anyway,
+// so that shortcuts are detected and missing paths report the first
+// missing component.
+func (d *OneDrive) resolvePath(ctx context.Context, pth string, fullInfo bool) (info NodeInfo, err error) {
+	parts := pathParts(pth)
+	if d.graph() && !d.CaseSensitivePaths && len(parts) > 0 && (fullInfo || d.PathCache == nil) {
+		info, err = d.resolveAddressed(ctx, parts)
+		if !isStatus(err, http.StatusNotFound) && !isStatus(err, http.StatusBadRequest) {
+			return
+		}
+	}
+	return d.resolveParts(ctx, parts, fullInfo, nil)
+}
+
+// resolveAddressed looks the path up by Graph's root:/path: addressing.
+func (d *OneDrive) resolveAddressed(ctx context.Context, parts []string) (info NodeInfo, err error) {
+	info, err = d.nodeRequest(ctx, "GET", d.childPath(graphRootId, strings.Join(parts, "/")), "", nil, nil, 200)
+	if err != nil {
+		return
+	}
+	if info.NodeType() == NodeTypeShortcut && d.FollowShortcuts {
+		return d.NodeInfoContext(ctx, info.Target)
+	}
+	return
+}
+
+// resolveParts resolves a path split by pathParts. Listings are looked up
+// in and added to listings if it is not nil. ctx is checked before every
+// component; once it is done ctx.Err() is returned.
+func (d *OneDrive) resolveParts(ctx context.Context, parts []string, fullInfo bool, listings map[string][]NodeInfo) (info NodeInfo, err error) {
+	// the cache is case-insensitive, like the server
+	cache := d.PathCache
+	if d.CaseSensitivePaths {
+		cache = nil
+	}
+
+	if id, ok := cache.Get("", ""); ok && (len(parts) > 0 || !fullInfo) {
+		info = NodeInfo{Id: id}
+	} else if root, ok := listings[""]; ok {
+		// the root is kept under the empty id
+		info = root[0]
+	} else {
+		info, err = d.RootInfoContext(ctx)
+		if err != nil {
+			return
+		}
+		cache.Put("", "", info.Id)
+		if listings != nil {
+			listings[""] = []NodeInfo{info}
+		}
+	}
+
+	for i, part := range parts {
+		if err = ctx.Err(); err != nil {
+			return NodeInfo{}, err
+		}
+		last := i == len(parts)-1
+
+		if id, ok := cache.Get(info.Id, part); ok && (!last || !fullInfo) {
+			info = NodeInfo{Id: id}
+			continue
+		}
+
+		var found bool
+		if info, found, err = d.findChildIn(ctx, info.Id, part, listings); err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return NodeInfo{}, err
+		}
+		if !found {
+			return NodeInfo{}, &NodeNotFoundError{Path: "/" + path.Join(parts[:i+1]...)}
+		}
+		if info.NodeType() == NodeTypeShortcut {
+			if d.FollowShortcuts {
+				if info, err = d.NodeInfoContext(ctx, info.Target); err != nil {
+					return
+				}
+			} else if !last {
+				return NodeInfo{}, fmt.Errorf("%w %s", ErrShortcut, "/"+path.Join(parts[:i+1]...))
+			}
+		}
+		if !last && !info.IsDir() {
+			return NodeInfo{}, fmt.Errorf("%w %s", ErrNotFolder, "/"+path.Join(parts[:i+1]...))
+		}
+	}
+	return
+}
+
+func (d *OneDrive) ResolvePaths(paths []string) (ids map[string]string, err error) {
+	return d.ResolvePathsContext(context.Background(), paths)
+}
+
+// ResolvePathsContext resolves all paths like ResolvePath, listing every
+// folder at most once, so that resolving many files of the same folder
+// costs a single listing. ids has an entry for every path that was
+// resolved. If some paths cannot be resolved, err is a *BatchError keyed
+// by path.
+func (d *OneDrive) ResolvePathsContext(ctx context.Context, paths []string) (ids map[string]string, err error) {
+	ids = make(map[string]string)
+	listings := make(map[string][]NodeInfo)
+	batchErr := &BatchError{Errors: make(map[string]error)}
+
+	for _, pth := range paths {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		info, perr := d.resolveParts(ctx, pathParts(pth), false, listings)
+		if perr != nil {
+			batchErr.Errors[pth] = perr
+			continue
+		}
+		ids[pth] = info.Id
+	}
+
+	if len(batchErr.Errors) > 0 {
+		err = batchErr
+	}
+	return
+}
+
+func (d *OneDrive) Exists(pth string) (exists bool, err error) {
+	return d.ExistsContext(context.Background(), pth)
+}
+
+// ExistsContext reports whether the path exists. A missing path is not an
+// error; any other failure is.
+func (d *OneDrive) ExistsContext(ctx context.Context, pth string) (exists bool, err error) {
+	defer wrapOp(&err, "Exists", pth)
+	_, err = d.ResolvePathContext(ctx, pth)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// findChild lists parentId and returns the child called name, comparing
+// names by NormalizeName like the server does unless d.CaseSensitivePaths
+// is set. The listing is added to the path cache.
+func (d *OneDrive) findChild(ctx context.Context, parentId string, name string) (child NodeInfo, found bool, err error) {
+	return d.findChildIn(ctx, parentId, name, nil)
+}
+
+// findChildIn is like findChild but reuses the listing of parentId from
+// listings, if it is not nil, and stores it there.
+func (d *OneDrive) findChildIn(ctx context.Context, parentId string, name string, listings map[string][]NodeInfo) (child NodeInfo, found bool, err error) {
+	files, ok := listings[parentId]
+	if !ok {
+		if files, err = d.NodeFilesContext(ctx, parentId); err != nil {
+			return
+		}
+		if listings != nil {
+			listings[parentId] = files
+		}
+	}
+
+	if d.CaseSensitivePaths {
+		return matchChildExact(files, name)
+	}
+
+	key := NormalizeName(name)
+	for _, file := range files {
+		switch {
+		case file.NodeType() != NodeTypeShortcut:
+			d.PathCache.Put(parentId, file.Name, file.Id)
+		case d.FollowShortcuts:
+			// cached paths lead to the target; unfollowed shortcuts are
+			// not cached so that they are detected mid-path
+			d.PathCache.Put(parentId, file.Name, file.Target)
+		}
+		if !found && NormalizeName(file.Name) == key {
+			child = file
+			found = true
+		}
+	}
+	return
+}
+
+// matchChildExact returns the file called exactly name, up to Unicode
+// normalization. If there is none but several files match name ignoring
+// case, the returned error matches ErrAmbiguous.
+func matchChildExact(files []NodeInfo, name string) (child NodeInfo, found bool, err error) {
+	folded := 0
+	for _, file := range files {
+		if norm.NFC.String(file.Name) == norm.NFC.String(name) {
+			return file, true, nil
+		}
+		if SameName(file.Name, name) {
+			folded++
+		}
+	}
+	if folded > 1 {
+		err = fmt.Errorf("%w %s", ErrAmbiguous, name)
+	}
+	return
+}
+
+func (d *OneDrive) EnsurePath(pth string) (info NodeInfo, err error) {
+	return d.EnsurePathContext(context.Background(), pth)
+}
+
+// EnsurePathContext resolves the path like ResolvePathInfo, creating any
+// missing folders along the way, and returns the info of the last folder.
+// A folder created concurrently by someone else is used as is. If a
+// component of the path exists but is not a folder the returned error
+// matches ErrNotFolder.
+func (d *OneDrive) EnsurePathContext(ctx context.Context, pth string) (info NodeInfo, err error) {
+	defer wrapOp(&err, "EnsurePath", pth)
+	info, err = d.RootInfoContext(ctx)
+	if err != nil {
+		return
+	}
+
+	parts := pathParts(pth)
+	for i, part := range parts {
+		var child NodeInfo
+		var found bool
+		if child, found, err = d.findChild(ctx, info.Id, part); err != nil {
+			return
+		}
+
+		if !found {
+			child, err = d.CreateFolderContext(ctx, info.Id, part)
+			if errors.Is(err, ErrConflict) {
+				// lost a race with another creator
+				if child, found, err = d.findChild(ctx, info.Id, part); err == nil && !found {
+					err = &NodeNotFoundError{Path: "/" + path.Join(parts[:i+1]...)}
+				}
+			}
+			if err != nil {
+				return NodeInfo{}, err
+			}
+		}
+
+		if !isFolderType(child.Type) {
+			return NodeInfo{}, fmt.Errorf("%w %s", ErrNotFolder, part)
+		}
+		info = child
+	}
+	return
+}
+
+func (d *OneDrive) FullPath(id string) (pth string, err error) {
+	return d.FullPathContext(context.Background(), id)
+}
+
+// FullPathContext reconstructs the path of the node from the root by
+// walking up its parents. Folders looked up on the way are cached so that
+// paths of nodes in the same tree are cheap to compute.
+func (d *OneDrive) FullPathContext(ctx context.Context, id string) (pth string, err error) {
+	defer wrapOp(&err, "FullPath", id)
+	info, err := d.NodeInfoContext(ctx, id)
+	if err != nil {
+		return
+	}
+
+	var names []string
+	for info.ParentId != "" {
+		names = append(names, info.Name)
+
+		parentId := info.ParentId
+		var ok bool
+		if info, ok = d.ancestors.get(parentId); !ok {
+			if info, err = d.NodeInfoContext(ctx, parentId); err != nil {
+				return
+			}
+			d.ancestors.put(info)
+		}
+
+		if len(names) > maxPathDepth {
+			err = fmt.Errorf("Path of %s is too deep", id)
+			return
+		}
+	}
+
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	pth = "/" + strings.Join(names, "/")
+	return
+}
+
+// maxPathDepth guards FullPath against parent cycles.
+const maxPathDepth = 1000
+
+func pathParts(pth string) []string {
+	pth = path.Clean("/" + pth)
+	parts := make([]string, 0)
+	for pth != "/" {
+		var name string
+		pth, name = path.Split(pth)
+		pth = path.Clean(pth)
+		parts = append(parts, name)
+	}
+
+	//in-place reverse
+	l := len(parts) - 1
+	h := len(parts) / 2
+	for i := 0; i < h; i++ {
+		t := parts[i]
+		ii := l - i
+		parts[i] = parts[ii]
+		parts[ii] = t
+	}
+	return parts
+}
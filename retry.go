@@ -0,0 +1,150 @@
+package onedriveclient
+
+import (
+	"context"
+	"github.com/koofr/go-httpclient"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxRetries  = 3
+	DefaultBaseBackoff = 1 * time.Second
+
+	maxBackoff = 1 * time.Minute
+)
+
+// request performs req using client, retrying throttled (429) and failed
+// (500, 502, 503, 504) requests up to d.MaxRetries times. Retry-After is
+// honored when the server sends it, otherwise the delay grows exponentially
+// from d.BaseBackoff with jitter. Requests with a non-seekable body are
+// never retried because the body cannot be sent again.
+//
+// Failed responses with an API error body are returned as *APIError.
+//
+// If an authenticated request is rejected with 401 although the token has
+// not expired yet, the token has probably been revoked: it is refreshed
+// and the request retried once with the new token.
+func (d *OneDrive) request(ctx context.Context, client *httpclient.HTTPClient, req *httpclient.RequestData) (res *http.Response, err error) {
+	rewind := func() error { return nil }
+	if req.ReqReader != nil {
+		s, ok := req.ReqReader.(io.Seeker)
+		if !ok {
+			res, err = d.do(client, req)
+			return res, parseAPIError(err)
+		}
+		offset, serr := s.Seek(0, io.SeekCurrent)
+		if serr != nil {
+			res, err = d.do(client, req)
+			return res, parseAPIError(err)
+		}
+		rewind = func() (err error) {
+			_, err = s.Seek(offset, io.SeekStart)
+			return
+		}
+	}
+
+	res, err = d.requestRetry(ctx, client, req, rewind)
+
+	auth := req.Headers.Get("Authorization")
+	if auth == "" || !httpclient.IsInvalidStatusCode(err, http.StatusUnauthorized) {
+		return res, parseAPIError(err)
+	}
+	if rerr := rewind(); rerr != nil {
+		return res, parseAPIError(err)
+	}
+
+	d.Auth.invalidateToken(strings.TrimPrefix(auth, "Bearer "))
+	header, aerr := d.AuthenticationHeaderContext(ctx)
+	if aerr != nil {
+		err = aerr
+		return
+	}
+	req.Headers.Set("Authorization", header.Get("Authorization"))
+	d.Stats.countRetry()
+
+	res, err = d.requestRetry(ctx, client, req, rewind)
+	return res, parseAPIError(err)
+}
+
+func (d *OneDrive) requestRetry(ctx context.Context, client *httpclient.HTTPClient, req *httpclient.RequestData, rewind func() error) (res *http.Response, err error) {
+	for attempt := 0; ; attempt++ {
+		res, err = d.do(client, req)
+		if err == nil || attempt >= d.MaxRetries {
+			return
+		}
+
+		ise, ok := httpclient.IsInvalidStatusError(err)
+		if !ok || !retryableStatus(ise.Got) {
+			return
+		}
+
+		delay, ok := retryAfter(ise.Headers)
+		if !ok {
+			delay = d.backoff(attempt)
+		}
+
+		if rerr := rewind(); rerr != nil {
+			return
+		}
+		d.Stats.countRetry()
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			err = ctx.Err()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(h http.Header) (delay time.Duration, ok bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		delay = time.Until(t)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return
+}
+
+func (d *OneDrive) backoff(attempt int) time.Duration {
+	base := d.BaseBackoff
+	if base <= 0 {
+		base = DefaultBaseBackoff
+	}
+	max := base << uint(attempt)
+	if max <= 0 || max > maxBackoff {
+		max = maxBackoff
+	}
+	// full jitter
+	return time.Duration(rand.Int63n(int64(max))) + 1
+}
@@ -0,0 +1,26 @@
+package onedriveclient
+
+import (
+	"context"
+	"net/url"
+)
+
+func (d *OneDrive) Search(query string) (files []NodeInfo, err error) {
+	return d.SearchContext(context.Background(), query)
+}
+
+// SearchContext returns all nodes whose name or content matches query,
+// following the paging links until the results are exhausted. ParentId is
+// set on every result to tell apart nodes with the same name.
+func (d *OneDrive) SearchContext(ctx context.Context, query string) (files []NodeInfo, err error) {
+	defer wrapOp(&err, "Search", query)
+	if d.graph() {
+		// results of other drives are shortcuts with a qualified Target
+		return d.allPages(ctx, "", graphSearchPath(query), nil)
+	}
+
+	params := url.Values{}
+	params.Set("q", query)
+
+	return d.allPages(ctx, "", "/me/skydrive/search", params)
+}
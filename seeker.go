@@ -0,0 +1,98 @@
+package onedriveclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrRangeUnsupported is returned by reads of DownloadSeeker when the
+// server ignores Range requests.
+var ErrRangeUnsupported = errors.New("Range requests not supported")
+
+func (d *OneDrive) DownloadSeeker(id string) (content io.ReadSeekCloser, size int64, err error) {
+	return d.DownloadSeekerContext(context.Background(), id)
+}
+
+// DownloadSeekerContext returns the content of the node for random access.
+// Nothing is downloaded until the first Read; after a Seek to another
+// offset the next Read starts a new ranged request from there. size is the
+// size of the file.
+func (d *OneDrive) DownloadSeekerContext(ctx context.Context, id string) (content io.ReadSeekCloser, size int64, err error) {
+	defer wrapOp(&err, "DownloadSeeker", id)
+	info, err := d.NodeInfoContext(ctx, id)
+	if err != nil {
+		return
+	}
+	if d.contentSource(info) == "" {
+		err = fmt.Errorf("Cannot download %s", id)
+		return
+	}
+	return &rangeReader{d: d, ctx: ctx, info: info}, info.Size, nil
+}
+
+type rangeReader struct {
+	d    *OneDrive
+	ctx  context.Context
+	info NodeInfo
+	pos  int64
+	body io.ReadCloser
+}
+
+func (r *rangeReader) Read(p []byte) (n int, err error) {
+	if r.pos >= r.info.Size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		var res *http.Response
+		if res, err = r.d.downloadSource(r.ctx, r.info, fmt.Sprintf("bytes=%d-", r.pos)); err != nil {
+			return
+		}
+		if r.pos > 0 && res.StatusCode != http.StatusPartialContent {
+			res.Body.Close()
+			return 0, fmt.Errorf("%w for %s", ErrRangeUnsupported, r.info.Id)
+		}
+		r.body = newContextReadCloser(r.ctx, res.Body)
+	}
+
+	n, err = r.body.Read(p)
+	r.pos += int64(n)
+	if err == io.EOF && r.pos < r.info.Size {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (pos int64, err error) {
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.info.Size + offset
+	default:
+		return r.pos, fmt.Errorf("Invalid whence %d", whence)
+	}
+	if pos < 0 {
+		return r.pos, fmt.Errorf("Negative position %d", pos)
+	}
+
+	if pos != r.pos && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.pos = pos
+	return
+}
+
+func (r *rangeReader) Close() (err error) {
+	if r.body != nil {
+		err = r.body.Close()
+		r.body = nil
+	}
+	return
+}
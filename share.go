@@ -0,0 +1,106 @@
+package onedriveclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/koofr/go-httpclient"
+	"net/http"
+)
+
+const (
+	LinkTypeView = "view"
+	LinkTypeEdit = "edit"
+)
+
+// ErrSharingUnavailable is matched (via errors.Is) when a link cannot be
+// created because sharing is disabled for the account or the node does not
+// support links.
+var ErrSharingUnavailable = errors.New("Sharing unavailable")
+
+func (d *OneDrive) SharedLink(id string, linkType string) (link string, err error) {
+	return d.SharedLinkContext(context.Background(), id, linkType)
+}
+
+// SharedLinkContext returns a link to the node that can be handed to other
+// people. linkType is LinkTypeView for a read-only link or LinkTypeEdit for
+// a link that allows editing.
+func (d *OneDrive) SharedLinkContext(ctx context.Context, id string, linkType string) (link string, err error) {
+	defer wrapOp(&err, "SharedLink", id)
+	var endpoint string
+	switch linkType {
+	case LinkTypeView:
+		endpoint = "shared_read_link"
+	case LinkTypeEdit:
+		endpoint = "shared_edit_link"
+	default:
+		err = fmt.Errorf("Unknown link type %s", linkType)
+		return
+	}
+
+	header, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+
+	if d.graph() {
+		return d.graphSharedLink(ctx, header, id, linkType)
+	}
+
+	resp := &struct {
+		Link string `json:"link"`
+	}{}
+
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         "GET",
+		Path:           "/" + id + "/" + endpoint,
+		Headers:        header,
+		ExpectedStatus: []int{200},
+		RespEncoding:   httpclient.EncodingJSON,
+		RespValue:      resp,
+	}
+	_, err = d.request(ctx, d.ApiClient, req)
+	if isStatus(err, http.StatusBadRequest) || isStatus(err, http.StatusForbidden) {
+		err = fmt.Errorf("%w for %s: %v", ErrSharingUnavailable, id, err)
+	}
+	if err != nil {
+		return
+	}
+
+	link = resp.Link
+	return
+}
+
+func (d *OneDrive) graphSharedLink(ctx context.Context, header http.Header, id string, linkType string) (link string, err error) {
+	resp := &struct {
+		Link struct {
+			WebUrl string `json:"webUrl"`
+		} `json:"link"`
+	}{}
+
+	req := &httpclient.RequestData{
+		Context:     ctx,
+		Method:      "POST",
+		Path:        d.itemPath(id) + "/createLink",
+		Headers:     header,
+		ReqEncoding: httpclient.EncodingJSON,
+		ReqValue: map[string]string{
+			"type":  linkType,
+			"scope": "anonymous",
+		},
+		ExpectedStatus: []int{200, 201},
+		RespEncoding:   httpclient.EncodingJSON,
+		RespValue:      resp,
+	}
+	_, err = d.request(ctx, d.ApiClient, req)
+	if isStatus(err, http.StatusBadRequest) || isStatus(err, http.StatusForbidden) {
+		err = fmt.Errorf("%w for %s: %v", ErrSharingUnavailable, id, err)
+	}
+	if err != nil {
+		return
+	}
+
+	link = resp.Link.WebUrl
+	return
+}
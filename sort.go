@@ -0,0 +1,59 @@
+package onedriveclient
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// SortKey selects the order of NodeFilesSorted.
+type SortKey int
+
+const (
+	// SortByName sorts by name, ignoring case.
+	SortByName SortKey = iota
+	// SortByModified sorts by UpdatedAt, oldest first.
+	SortByModified
+	// SortBySize sorts by Size, smallest first.
+	SortBySize
+)
+
+func (d *OneDrive) NodeFilesSorted(id string, by SortKey) (files []NodeInfo, err error) {
+	return d.NodeFilesSortedContext(context.Background(), id, by)
+}
+
+// NodeFilesSortedContext lists all children of the node like
+// NodeFilesContext and sorts them. Ties are broken by name and then by id,
+// so the order does not depend on the server's.
+func (d *OneDrive) NodeFilesSortedContext(ctx context.Context, id string, by SortKey) (files []NodeInfo, err error) {
+	defer wrapOp(&err, "NodeFilesSorted", id)
+	if files, err = d.NodeFilesContext(ctx, id); err != nil {
+		return
+	}
+	SortNodes(files, by)
+	return
+}
+
+// SortNodes sorts nodes like NodeFilesSorted.
+func SortNodes(nodes []NodeInfo, by SortKey) {
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		switch by {
+		case SortByModified:
+			if !a.UpdatedAt.Equal(b.UpdatedAt) {
+				return a.UpdatedAt.Before(b.UpdatedAt)
+			}
+		case SortBySize:
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		}
+		if an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name); an != bn {
+			return an < bn
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Id < b.Id
+	})
+}
@@ -0,0 +1,64 @@
+package onedriveclient
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Stats counts requests for monitoring. Set the same *Stats as
+// OneDrive.Stats and OneDriveAuth.Stats to count token refreshes too. A nil
+// *Stats counts nothing. It is safe for concurrent use.
+type Stats struct {
+	requests       atomic.Int64
+	retries        atomic.Int64
+	throttled      atomic.Int64
+	tokenRefreshes atomic.Int64
+}
+
+// StatsSnapshot is a copy of the counters of Stats, e.g. for publishing
+// with expvar.Func.
+type StatsSnapshot struct {
+	// Requests is the number of HTTP requests sent, including retries.
+	Requests int64
+	// Retries is the number of requests that were retries.
+	Retries int64
+	// Throttled is the number of 429 responses received.
+	Throttled int64
+	// TokenRefreshes is the number of token requests sent.
+	TokenRefreshes int64
+}
+
+// Snapshot returns the current counters. It is zero for a nil *Stats.
+func (s *Stats) Snapshot() (snapshot StatsSnapshot) {
+	if s == nil {
+		return
+	}
+	return StatsSnapshot{
+		Requests:       s.requests.Load(),
+		Retries:        s.retries.Load(),
+		Throttled:      s.throttled.Load(),
+		TokenRefreshes: s.tokenRefreshes.Load(),
+	}
+}
+
+func (s *Stats) countRequest(status int) {
+	if s == nil {
+		return
+	}
+	s.requests.Add(1)
+	if status == http.StatusTooManyRequests {
+		s.throttled.Add(1)
+	}
+}
+
+func (s *Stats) countRetry() {
+	if s != nil {
+		s.retries.Add(1)
+	}
+}
+
+func (s *Stats) countTokenRefresh() {
+	if s != nil {
+		s.tokenRefreshes.Add(1)
+	}
+}
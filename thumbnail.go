@@ -0,0 +1,127 @@
+package onedriveclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/koofr/go-httpclient"
+	"io"
+	"net/http"
+)
+
+const (
+	ThumbnailSmall  = "small"
+	ThumbnailMedium = "medium"
+	ThumbnailLarge  = "large"
+)
+
+// ErrThumbnailUnavailable is matched (via errors.Is) when the node has no
+// thumbnail of the requested size.
+var ErrThumbnailUnavailable = errors.New("Thumbnail unavailable")
+
+var thumbnailImageTypes = map[string]string{
+	ThumbnailSmall:  "thumbnail",
+	ThumbnailMedium: "album",
+	ThumbnailLarge:  "normal",
+}
+
+// ThumbnailURL returns the URL of the thumbnail of the given size (one of
+// ThumbnailSmall, ThumbnailMedium and ThumbnailLarge) from the node's
+// images. It can be handed to a browser directly.
+func (info NodeInfo) ThumbnailURL(size string) (url string, ok bool) {
+	imageType, ok := thumbnailImageTypes[size]
+	if !ok {
+		return
+	}
+	for _, image := range info.Images {
+		if image.Type == imageType && image.Source != "" {
+			return image.Source, true
+		}
+	}
+	if size == ThumbnailSmall && info.Picture != "" {
+		return info.Picture, true
+	}
+	return "", false
+}
+
+func (d *OneDrive) Thumbnail(id string, size string) (content io.ReadCloser, err error) {
+	return d.ThumbnailContext(context.Background(), id, size)
+}
+
+// ThumbnailContext streams the thumbnail of the given size. Nodes without
+// thumbnails, such as folders, return an error matching
+// ErrThumbnailUnavailable.
+func (d *OneDrive) ThumbnailContext(ctx context.Context, id string, size string) (content io.ReadCloser, err error) {
+	defer wrapOp(&err, "Thumbnail", id)
+	if _, ok := thumbnailImageTypes[size]; !ok {
+		err = fmt.Errorf("Unknown thumbnail size %s", size)
+		return
+	}
+
+	var url string
+	var ok bool
+	if d.graph() {
+		url, ok, err = d.graphThumbnailURL(ctx, id, size)
+	} else {
+		var info NodeInfo
+		if info, err = d.NodeInfoContext(ctx, id); err == nil {
+			url, ok = info.ThumbnailURL(size)
+		}
+	}
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = fmt.Errorf("%w for %s", ErrThumbnailUnavailable, id)
+		return
+	}
+
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         "GET",
+		FullURL:        url,
+		ExpectedStatus: []int{http.StatusOK},
+	}
+	res, err := d.request(ctx, d.ContentClient, req)
+	if err != nil {
+		return
+	}
+
+	content = newContextReadCloser(ctx, res.Body)
+	return
+}
+
+type graphThumbnail struct {
+	Url string `json:"url"`
+}
+
+func (d *OneDrive) graphThumbnailURL(ctx context.Context, id string, size string) (url string, ok bool, err error) {
+	header, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+
+	resp := &struct {
+		Value []map[string]graphThumbnail `json:"value"`
+	}{}
+
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         "GET",
+		Path:           d.itemPath(id) + "/thumbnails",
+		Headers:        header,
+		ExpectedStatus: []int{200},
+		RespEncoding:   httpclient.EncodingJSON,
+		RespValue:      resp,
+	}
+	if _, err = d.request(ctx, d.ApiClient, req); err != nil {
+		return
+	}
+
+	for _, set := range resp.Value {
+		if thumb, found := set[size]; found && thumb.Url != "" {
+			return thumb.Url, true, nil
+		}
+	}
+	return
+}
@@ -0,0 +1,36 @@
+package onedriveclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrRestoreUnavailable is matched by errors of Restore when the backend or
+// the drive cannot restore deleted nodes.
+var ErrRestoreUnavailable = errors.New("Restore unavailable")
+
+func (d *OneDrive) Restore(id string) (info NodeInfo, err error) {
+	return d.RestoreContext(context.Background(), id)
+}
+
+// RestoreContext moves a deleted node out of the recycle bin back to where
+// it was and returns its info. Only BackendGraph with a personal drive
+// supports it; neither API can list the recycle bin.
+func (d *OneDrive) RestoreContext(ctx context.Context, id string) (info NodeInfo, err error) {
+	defer wrapOp(&err, "Restore", id)
+	if !d.graph() {
+		err = fmt.Errorf("%w for %s: not supported by the Live API", ErrRestoreUnavailable, id)
+		return
+	}
+
+	info, err = d.nodeRequest(ctx, "POST", d.itemPath(id)+"/restore", d.remoteDrive(id), nil, map[string]interface{}{}, 200)
+	if isStatus(err, http.StatusNotFound) {
+		err = &NodeNotFoundError{Id: id}
+	}
+	if isStatus(err, http.StatusBadRequest) || isStatus(err, http.StatusNotImplemented) {
+		err = fmt.Errorf("%w for %s: %v", ErrRestoreUnavailable, id, err)
+	}
+	return
+}
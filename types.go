@@ -1,20 +1,253 @@
 package onedriveclient
 
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type RefreshResp struct {
-	ExpiresIn   int64  `json:"expires_in"`
-	AccessToken string `json:"access_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// Scope lists the granted scopes, separated by spaces.
+	Scope string `json:"scope,omitempty"`
+
+	hasExpiresIn bool
+}
+
+// UnmarshalJSON accepts expires_in both as a number (live.com, v2.0) and as
+// a string (Azure AD v1.0).
+func (r *RefreshResp) UnmarshalJSON(data []byte) (err error) {
+	type refreshResp RefreshResp
+	aux := struct {
+		*refreshResp
+		ExpiresIn json.RawMessage `json:"expires_in"`
+	}{refreshResp: (*refreshResp)(r)}
+
+	if err = json.Unmarshal(data, &aux); err != nil {
+		return
+	}
+
+	r.ExpiresIn = 0
+	r.hasExpiresIn = false
+	if raw := strings.Trim(string(aux.ExpiresIn), `"`); raw != "" && raw != "null" {
+		if r.ExpiresIn, err = strconv.ParseInt(raw, 10, 64); err != nil {
+			return fmt.Errorf("Invalid expires_in %s", aux.ExpiresIn)
+		}
+		r.hasExpiresIn = true
+	}
+	return
 }
 
 type NodeInfo struct {
-	Id          string `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Size        int64  `json:"size"`
-	Type        string `json:"type"`
-	UpdatedTime string `json:"updated_time"`
-	Source      string `json:"source,omitempty"`
+	Id          string  `json:"id"`
+	Name        string  `json:"name"`
+	ParentId    string  `json:"parent_id,omitempty"`
+	Description string  `json:"description"`
+	Size        int64   `json:"size"`
+	Type        string  `json:"type"`
+	CreatedTime string  `json:"created_time,omitempty"`
+	UpdatedTime string  `json:"updated_time"`
+	Source      string  `json:"source,omitempty"`
+	Hashes      Hashes  `json:"hashes"`
+	Picture     string  `json:"picture,omitempty"`
+	Images      []Image `json:"images,omitempty"`
+	// ImageInfo holds the metadata of photos. It is nil for other nodes.
+	ImageInfo *ImageInfo `json:"-"`
+	// ETag identifies the version of the node. Only BackendGraph reports
+	// it.
+	ETag string `json:"-"`
+	// Target is the id of the node a shortcut refers to.
+	Target string `json:"-"`
+	// RawJSON is the node as sent by the server, for fields NodeInfo does
+	// not map.
+	RawJSON json.RawMessage `json:"-"`
+
+	// CreatedAt and UpdatedAt are parsed from CreatedTime and UpdatedTime.
+	// They are zero if the server did not send a valid timestamp.
+	CreatedAt time.Time `json:"-"`
+	UpdatedAt time.Time `json:"-"`
+}
+
+func (info *NodeInfo) UnmarshalJSON(data []byte) (err error) {
+	type nodeInfo NodeInfo
+	if err = json.Unmarshal(data, (*nodeInfo)(info)); err != nil {
+		return
+	}
+	info.RawJSON = append(json.RawMessage(nil), data...)
+	info.parseTimes()
+	if info.NodeType() == NodeTypePhoto {
+		info.ImageInfo, err = parseLiveImageInfo(data)
+	}
+	return
+}
+
+// parseLiveImageInfo reads the photo fields of a Live API node.
+func parseLiveImageInfo(data []byte) (image *ImageInfo, err error) {
+	var photo struct {
+		Width       int       `json:"width"`
+		Height      int       `json:"height"`
+		WhenTaken   string    `json:"when_taken"`
+		CameraMake  string    `json:"camera_make"`
+		CameraModel string    `json:"camera_model"`
+		Location    *Location `json:"location"`
+	}
+	if err = json.Unmarshal(data, &photo); err != nil {
+		return
+	}
+	image = &ImageInfo{
+		Width:       photo.Width,
+		Height:      photo.Height,
+		TakenAt:     parseTime(photo.WhenTaken),
+		CameraMake:  photo.CameraMake,
+		CameraModel: photo.CameraModel,
+		Location:    photo.Location,
+	}
+	return
+}
+
+func (info *NodeInfo) parseTimes() {
+	info.CreatedAt = parseTime(info.CreatedTime)
+	info.UpdatedAt = parseTime(info.UpdatedTime)
+}
+
+// parseTime parses the API's timestamps. The Live API sends offsets without
+// a colon ("2013-09-23T18:30:27+0000"), Graph sends RFC 3339.
+func parseTime(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05-0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Image is a rendition of a photo. Type is one of "thumbnail", "album",
+// "normal" and "full".
+type Image struct {
+	Type   string `json:"type"`
+	Source string `json:"source"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ImageInfo is the metadata of a photo. Fields the server does not report
+// are zero; TakenAt is zero if the time the photo was taken is unknown.
+type ImageInfo struct {
+	Width       int
+	Height      int
+	TakenAt     time.Time
+	CameraMake  string
+	CameraModel string
+	// Location is where the photo was taken, if known.
+	Location *Location
+}
+
+// Location is a geographic position. Altitude is in meters.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+}
+
+// Hashes holds the content hashes the server reports for a file. Either may
+// be empty; folders have none.
+type Hashes struct {
+	Sha1     string `json:"sha1Hash,omitempty"`
+	QuickXor string `json:"quickXorHash,omitempty"`
 }
 
 type NodeFiles struct {
-	Data []NodeInfo `json:"data"`
+	Data   []NodeInfo `json:"data"`
+	Paging Paging     `json:"paging"`
+}
+
+type Paging struct {
+	Previous string `json:"previous,omitempty"`
+	Next     string `json:"next,omitempty"`
+}
+
+type QuotaInfo struct {
+	Total     int64 `json:"quota"`
+	Remaining int64 `json:"available"`
+	Used      int64 `json:"-"`
+}
+
+// NodeType classifies nodes by the API's type field.
+type NodeType int
+
+const (
+	NodeTypeUnknown NodeType = iota
+	NodeTypeFolder
+	NodeTypeAlbum
+	NodeTypeFile
+	NodeTypePhoto
+	NodeTypeVideo
+	NodeTypeAudio
+	NodeTypeNotebook
+	// NodeTypeShortcut is a reference to a node in another drive, such as
+	// a shared folder added to the user's drive. NodeInfo.Target is the id
+	// of the node it refers to.
+	NodeTypeShortcut
+)
+
+var nodeTypeNames = map[string]NodeType{
+	"folder":   NodeTypeFolder,
+	"album":    NodeTypeAlbum,
+	"file":     NodeTypeFile,
+	"photo":    NodeTypePhoto,
+	"video":    NodeTypeVideo,
+	"audio":    NodeTypeAudio,
+	"notebook": NodeTypeNotebook,
+	"shortcut": NodeTypeShortcut,
+}
+
+func ParseNodeType(t string) NodeType {
+	return nodeTypeNames[t]
+}
+
+func (t NodeType) String() string {
+	for name, nt := range nodeTypeNames {
+		if nt == t {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// IsDir reports whether nodes of this type can have children.
+func (t NodeType) IsDir() bool {
+	return t == NodeTypeFolder || t == NodeTypeAlbum
+}
+
+// IsFile reports whether nodes of this type have content.
+func (t NodeType) IsFile() bool {
+	return t != NodeTypeUnknown && !t.IsDir()
+}
+
+// NodeType classifies the node by its Type field.
+func (info NodeInfo) NodeType() NodeType {
+	return ParseNodeType(info.Type)
+}
+
+func (info NodeInfo) IsDir() bool {
+	return info.NodeType().IsDir()
+}
+
+func (info NodeInfo) IsFile() bool {
+	return info.NodeType().IsFile()
+}
+
+// IsFolder reports whether the node is a folder (or an album) and can have
+// children.
+func IsFolder(info NodeInfo) bool {
+	return info.IsDir()
+}
+
+// isFolderType reports whether nodes of the given type can have children.
+func isFolderType(t string) bool {
+	return ParseNodeType(t).IsDir()
 }
@@ -0,0 +1,496 @@
+package onedriveclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/koofr/go-httpclient"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Upload session chunks must be a multiple of 320 KiB.
+	UploadChunkAlignment   = 320 * 1024
+	DefaultUploadChunkSize = 32 * UploadChunkAlignment
+
+	// DefaultSimpleUploadMaxBytes is the default of
+	// OneDrive.SimpleUploadMaxBytes. Microsoft Graph only recommends single
+	// request uploads up to 4 MB.
+	DefaultSimpleUploadMaxBytes = 4 * 1024 * 1024
+
+	maxChunkAttempts = 5
+)
+
+type uploadSession struct {
+	UploadUrl          string   `json:"uploadUrl"`
+	ExpirationDateTime string   `json:"expirationDateTime"`
+	NextExpectedRanges []string `json:"nextExpectedRanges"`
+}
+
+// driveItemPath returns the OneDrive API path of a Live SDK node id. Live
+// SDK ids look like "folder.8bf6ae9dbc6caa4c.8BF6AE9DBC6CAA4C!103" where
+// the last component is the OneDrive API item id.
+func driveItemPath(id string) string {
+	if id == "" || id == "me/skydrive" {
+		return "/drive/root"
+	}
+	if parts := strings.SplitN(id, ".", 3); len(parts) == 3 {
+		id = parts[2]
+	}
+	return "/drive/items/" + id
+}
+
+func (d *OneDrive) UploadPath(pth string, overwrite bool, createParents bool, content io.Reader) (info NodeInfo, err error) {
+	return d.UploadPathContext(context.Background(), pth, overwrite, createParents, content)
+}
+
+// UploadPathContext uploads content to the given path. The parent folder
+// must exist unless createParents is set, in which case missing folders are
+// created like EnsurePath does.
+func (d *OneDrive) UploadPathContext(ctx context.Context, pth string, overwrite bool, createParents bool, content io.Reader) (info NodeInfo, err error) {
+	defer wrapOp(&err, "UploadPath", pth)
+	dir, name := path.Split(path.Clean("/" + pth))
+	if name == "" {
+		err = fmt.Errorf("Invalid upload path %s", pth)
+		return
+	}
+
+	var parent NodeInfo
+	if createParents {
+		parent, err = d.EnsurePathContext(ctx, dir)
+	} else {
+		parent.Id, err = d.ResolvePathContext(ctx, dir)
+	}
+	if err != nil {
+		return
+	}
+
+	return d.UploadOverwriteInfoContext(ctx, parent.Id, name, overwrite, content)
+}
+
+func (d *OneDrive) UploadFile(dirId string, name string, localPath string, overwrite bool) (info NodeInfo, err error) {
+	return d.UploadFileContext(context.Background(), dirId, name, localPath, overwrite)
+}
+
+// UploadFileContext uploads the local file as dirId/name. Files for which
+// ShouldUseSession is true are uploaded with an upload session. If overwrite
+// is not set and the file exists, the server picks a new name for the
+// upload.
+func (d *OneDrive) UploadFileContext(ctx context.Context, dirId string, name string, localPath string, overwrite bool) (info NodeInfo, err error) {
+	defer wrapOp(&err, "UploadFile", dirId+"/"+name)
+	f, err := os.Open(localPath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return
+	}
+	if stat.IsDir() {
+		err = fmt.Errorf("Cannot upload %s: is a directory", localPath)
+		return
+	}
+
+	if info, err = d.uploadSized(ctx, dirId, name, f, stat.Size(), overwrite); err != nil {
+		err = fmt.Errorf("Cannot upload %s: %w", localPath, opCause(err))
+	}
+	return
+}
+
+// ShouldUseSession reports whether uploads of size bytes, such as those of
+// UploadFile and UploadOverwrite, are sent with an upload session rather
+// than a single request, i.e. whether d uses BackendGraph and size exceeds
+// d.SimpleUploadMaxBytes. With BackendLive it is always false: sessions
+// there return OneDrive API ids and skip Content-Type detection, so large
+// files have to be sent with UploadSession explicitly.
+func (d *OneDrive) ShouldUseSession(size int64) bool {
+	max := d.SimpleUploadMaxBytes
+	if max <= 0 {
+		max = DefaultSimpleUploadMaxBytes
+	}
+	return d.graph() && size > max
+}
+
+// uploadSized uploads size bytes of content in a single request or, if
+// ShouldUseSession says so, with an upload session.
+func (d *OneDrive) uploadSized(ctx context.Context, dirId string, name string, content io.Reader, size int64, overwrite bool) (info NodeInfo, err error) {
+	if d.ShouldUseSession(size) {
+		conflict := ConflictRename
+		if overwrite {
+			conflict = ConflictOverwrite
+		}
+		return d.uploadResumable(ctx, dirId, name, size, content, conflict, nil)
+	}
+	return d.UploadWithOptionsContext(ctx, dirId, name, content, UploadOptions{Overwrite: overwrite, Size: size})
+}
+
+func (d *OneDrive) UploadIfChanged(dirId string, name string, content io.ReadSeeker) (info NodeInfo, changed bool, err error) {
+	return d.UploadIfChangedContext(context.Background(), dirId, name, content)
+}
+
+// UploadIfChangedContext uploads content as dirId/name, replacing an
+// existing file, unless the existing file's hash already matches content.
+// In that case nothing is uploaded, info is the existing file and changed is
+// false. content is hashed from its current offset and rewound before it is
+// uploaded. Files the server reports no hash for are always uploaded.
+func (d *OneDrive) UploadIfChangedContext(ctx context.Context, dirId string, name string, content io.ReadSeeker) (info NodeInfo, changed bool, err error) {
+	defer wrapOp(&err, "UploadIfChanged", dirId+"/"+name)
+	start, err := content.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return
+	}
+	end, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+	if _, err = content.Seek(start, io.SeekStart); err != nil {
+		return
+	}
+
+	existing, found, err := d.findChild(ctx, dirId, name)
+	if err != nil {
+		return
+	}
+
+	if found && existing.IsFile() && existing.Size == end-start {
+		var same bool
+		if same, err = sameContent(existing.Hashes, content); err != nil {
+			return
+		}
+		if _, err = content.Seek(start, io.SeekStart); err != nil {
+			return
+		}
+		if same {
+			return existing, false, nil
+		}
+	}
+
+	info, err = d.uploadSized(ctx, dirId, name, content, end-start, true)
+	changed = err == nil
+	return
+}
+
+// sameContent reports whether content hashes to hs. It is false if hs is
+// empty.
+func sameContent(hs Hashes, content io.Reader) (same bool, err error) {
+	h, expected, sum := hashes(hs)
+	if h == nil {
+		return
+	}
+
+	if _, err = io.Copy(h, content); err != nil {
+		return
+	}
+	return sum(h) == expected, nil
+}
+
+func (d *OneDrive) UploadSession(dirId string, name string, size int64, content io.Reader) (info NodeInfo, err error) {
+	return d.UploadSessionContext(context.Background(), dirId, name, size, content)
+}
+
+func (d *OneDrive) UploadSessionWithProgress(dirId string, name string, size int64, content io.Reader, progress ProgressFunc) (info NodeInfo, err error) {
+	return d.UploadSessionWithProgressContext(context.Background(), dirId, name, size, content, progress)
+}
+
+// UploadSessionContext uploads size bytes of content as dirId/name using a
+// resumable upload session, replacing an existing file. The content is sent
+// in chunks of d.UploadChunkSize bytes. A chunk that fails is retried; the
+// upload resumes from the last byte the server confirmed.
+//
+// With BackendLive, upload sessions are provided by the OneDrive API
+// (d.SessionClient), which only returns a subset of the node fields: Id,
+// Name and Size.
+//
+// To resume an upload after a restart, create the session with
+// CreateUploadSession, persist it and send the content with
+// ResumeUploadSession instead.
+func (d *OneDrive) UploadSessionContext(ctx context.Context, dirId string, name string, size int64, content io.Reader) (info NodeInfo, err error) {
+	defer wrapOp(&err, "UploadSession", dirId+"/"+name)
+	return d.UploadSessionWithProgressContext(ctx, dirId, name, size, content, nil)
+}
+
+// UploadSessionWithProgressContext is like UploadSessionContext but calls
+// progress after every chunk the server confirms.
+func (d *OneDrive) UploadSessionWithProgressContext(ctx context.Context, dirId string, name string, size int64, content io.Reader, progress ProgressFunc) (info NodeInfo, err error) {
+	defer wrapOp(&err, "UploadSessionWithProgress", dirId+"/"+name)
+	return d.uploadResumable(ctx, dirId, name, size, content, ConflictOverwrite, progress)
+}
+
+func (d *OneDrive) uploadResumable(ctx context.Context, dirId string, name string, size int64, content io.Reader, conflict ConflictBehavior, progress ProgressFunc) (info NodeInfo, err error) {
+	if size <= 0 {
+		err = fmt.Errorf("Upload session requires a positive size, got %d", size)
+		return
+	}
+
+	session, err := d.createUploadSession(ctx, dirId, name, conflict)
+	if err != nil {
+		return
+	}
+
+	return d.sendChunks(ctx, session.UploadUrl, dirId, name, content, 0, size, progress)
+}
+
+// sendChunks uploads the content, which starts at byte from of the file,
+// to the upload session for dirId/name in chunks of d.chunkSize().
+func (d *OneDrive) sendChunks(ctx context.Context, uploadUrl string, dirId string, name string, content io.Reader, from int64, size int64, progress ProgressFunc) (info NodeInfo, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	chunks, release, wait := readChunks(ctx, content, size-from, d.chunkSize(), d.UploadReadAhead)
+	// content must not be read anymore once sendChunks returns
+	defer wait()
+	defer cancel()
+
+	for c := range chunks {
+		if c.err != nil {
+			err = c.err
+			return
+		}
+
+		offset := from + c.offset
+		var done bool
+		done, info, err = d.uploadChunkResumable(ctx, uploadUrl, dirId, name, offset, c.data, size)
+		if err != nil {
+			return
+		}
+		release(c.data)
+		if progress != nil {
+			progress(offset+int64(len(c.data)), size)
+		}
+		if done {
+			return
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
+
+	err = fmt.Errorf("Upload session did not complete")
+	return
+}
+
+type chunk struct {
+	offset int64
+	data   []byte
+	err    error
+}
+
+// readChunks reads size bytes of content in chunks of chunkSize in the
+// background, staying up to ahead chunks ahead of the consumer, which has
+// to release every chunk's data once it is done with it. Reading stops
+// after the first error or once ctx is done. wait blocks until the reading
+// goroutine has exited; after canceling ctx it returns as soon as a read
+// in progress does.
+func readChunks(ctx context.Context, content io.Reader, size int64, chunkSize int64, ahead int) (chunks <-chan chunk, release func([]byte), wait func()) {
+	if ahead < 0 {
+		ahead = 0
+	}
+	if chunkSize > size {
+		chunkSize = size
+	}
+
+	bufs := make(chan []byte, ahead+1)
+	for i := 0; i <= ahead; i++ {
+		bufs <- make([]byte, chunkSize)
+	}
+	out := make(chan chunk, ahead)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer close(out)
+		for offset := int64(0); offset < size; {
+			var buf []byte
+			select {
+			case buf = <-bufs:
+			case <-ctx.Done():
+				return
+			}
+
+			n := chunkSize
+			if size-offset < n {
+				n = size - offset
+			}
+			_, err := io.ReadFull(content, buf[:n])
+
+			select {
+			case out <- chunk{offset: offset, data: buf[:n], err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+			offset += n
+		}
+	}()
+
+	release = func(data []byte) {
+		bufs <- data[:cap(data)]
+	}
+	wait = func() {
+		<-done
+	}
+	return out, release, wait
+}
+
+func (d *OneDrive) chunkSize() int64 {
+	size := d.UploadChunkSize
+	if size <= 0 {
+		return DefaultUploadChunkSize
+	}
+	if size < UploadChunkAlignment {
+		return UploadChunkAlignment
+	}
+	return size - size%UploadChunkAlignment
+}
+
+func (d *OneDrive) createUploadSession(ctx context.Context, dirId string, name string, conflict ConflictBehavior) (session uploadSession, err error) {
+	header, err := d.AuthenticationHeaderContext(ctx)
+	if err != nil {
+		return
+	}
+
+	pth := driveItemPath(dirId) + ":/" + name + ":/upload.createSession"
+	conflictBehavior := "@name.conflictBehavior"
+	if d.graph() {
+		pth = d.childPath(dirId, name) + "/createUploadSession"
+		conflictBehavior = "@microsoft.graph.conflictBehavior"
+	}
+
+	req := &httpclient.RequestData{
+		Context:     ctx,
+		Method:      "POST",
+		Path:        pth,
+		Headers:     header,
+		ReqEncoding: httpclient.EncodingJSON,
+		ReqValue: map[string]interface{}{
+			"item": map[string]string{
+				conflictBehavior: graphConflictValues[conflict],
+			},
+		},
+		ExpectedStatus: []int{200},
+		RespEncoding:   httpclient.EncodingJSON,
+		RespValue:      &session,
+	}
+	_, err = d.request(ctx, d.SessionClient, req)
+	if conflict == ConflictFail && isConflict(err) {
+		err = fmt.Errorf("%w %s", ErrConflict, name)
+	}
+	return
+}
+
+// uploadChunkResumable sends chunk, which starts at offset, retrying after
+// failures from the offset the server reports as next expected.
+func (d *OneDrive) uploadChunkResumable(ctx context.Context, uploadUrl string, dirId string, name string, offset int64, chunk []byte, size int64) (done bool, info NodeInfo, err error) {
+	sent := int64(0)
+	end := int64(len(chunk))
+
+	for attempt := 1; ; attempt++ {
+		done, info, err = d.uploadChunk(ctx, uploadUrl, d.remoteDrive(dirId), offset+sent, chunk[sent:], size)
+		if err == nil || ctx.Err() != nil || attempt >= maxChunkAttempts {
+			return
+		}
+
+		next, serr := d.uploadSessionNext(ctx, uploadUrl)
+		if serr != nil || next < offset || next > offset+end {
+			return
+		}
+		if next == offset+end {
+			// the failed response was lost but the chunk arrived; after
+			// the last chunk the file is committed without us seeing it
+			if next == size {
+				info, err = d.committedItem(ctx, dirId, name)
+				return err == nil, info, err
+			}
+			return false, NodeInfo{}, nil
+		}
+		sent = next - offset
+
+		t := time.NewTimer(d.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			err = ctx.Err()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (d *OneDrive) uploadChunk(ctx context.Context, uploadUrl string, driveId string, offset int64, chunk []byte, size int64) (done bool, info NodeInfo, err error) {
+	end := offset + int64(len(chunk)) - 1
+
+	headers := make(http.Header)
+	headers.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, end, size))
+
+	req := &httpclient.RequestData{
+		Context:          ctx,
+		Method:           "PUT",
+		FullURL:          uploadUrl,
+		Headers:          headers,
+		ReqReader:        bytes.NewReader(chunk),
+		ReqContentLength: int64(len(chunk)),
+		ExpectedStatus:   []int{200, 201, 202},
+	}
+	res, err := d.request(ctx, d.ContentClient, req)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusAccepted {
+		return
+	}
+
+	done = true
+	target, finish := d.nodeTarget(&info, driveId)
+	if err = json.NewDecoder(res.Body).Decode(target); err == nil {
+		finish()
+	}
+	return
+}
+
+// committedItem looks up the file an upload session committed as
+// dirId/name, for when the server's final response did not arrive.
+func (d *OneDrive) committedItem(ctx context.Context, dirId string, name string) (info NodeInfo, err error) {
+	if name == "" {
+		err = fmt.Errorf("Upload session completed but the uploaded file is unknown")
+		return
+	}
+	info, found, err := d.findChild(ctx, dirId, name)
+	if err == nil && !found {
+		err = fmt.Errorf("%w %s", ErrNotFound, name)
+	}
+	return
+}
+
+// uploadSessionNext asks the server for the first byte it still expects.
+func (d *OneDrive) uploadSessionNext(ctx context.Context, uploadUrl string) (next int64, err error) {
+	var session uploadSession
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         "GET",
+		FullURL:        uploadUrl,
+		ExpectedStatus: []int{200},
+		RespEncoding:   httpclient.EncodingJSON,
+		RespValue:      &session,
+	}
+	if _, err = d.request(ctx, d.ContentClient, req); err != nil {
+		return
+	}
+	if len(session.NextExpectedRanges) == 0 {
+		err = fmt.Errorf("Upload session has no expected ranges")
+		return
+	}
+	// ranges look like "12345-" or "12345-67890"
+	start := strings.SplitN(session.NextExpectedRanges[0], "-", 2)[0]
+	return strconv.ParseInt(start, 10, 64)
+}
@@ -0,0 +1,129 @@
+package onedriveclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newSessionTestClient returns a Graph client whose server holds an upload
+// session for root/a.txt of size bytes, of which it has received the first
+// received bytes. The responses to PUTs of the last chunk are dropped, as
+// if the connection failed after the server had committed the file.
+func newSessionTestClient(t *testing.T, size int64, received int64) *OneDrive {
+	var srvURL string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/me/drive/root:/a.txt:/createUploadSession", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(uploadSession{UploadUrl: srvURL + "/session", NextExpectedRanges: []string{"0-"}})
+	})
+	mux.HandleFunc("/session", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			json.NewEncoder(w).Encode(uploadSession{NextExpectedRanges: []string{fmt.Sprintf("%d-", received)}})
+			return
+		}
+		if received >= size {
+			t.Errorf("got PUT %s after the upload completed", r.Header.Get("Content-Range"))
+		}
+		var start, end, total int64
+		fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total)
+		io.Copy(io.Discard, r.Body)
+		received = end + 1
+		if received < size {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+	mux.HandleFunc("/me/drive/root/children", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"value":[{"id":"1","name":"a.txt","size":%d,"file":{}}]}`, size)
+	})
+	d := newGraphTestClient(t, mux)
+	srvURL = d.ApiClient.BaseURL.String()
+	return d
+}
+
+func TestUploadSessionLostFinalResponse(t *testing.T) {
+	d := newSessionTestClient(t, 5, 0)
+
+	info, err := d.UploadSession("root", "a.txt", 5, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != "1" || info.Size != 5 {
+		t.Errorf("got %s of %d bytes, want 1 of 5 bytes", info.Id, info.Size)
+	}
+}
+
+func TestResumeUploadSessionComplete(t *testing.T) {
+	d := newSessionTestClient(t, 5, 5)
+	session := UploadSessionInfo{
+		UploadURL: d.ApiClient.BaseURL.String() + "/session",
+		DirId:     "root",
+		Name:      "a.txt",
+	}
+
+	info, err := d.ResumeUploadSession(session, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Id != "1" || info.Size != 5 {
+		t.Errorf("got %s of %d bytes, want 1 of 5 bytes", info.Id, info.Size)
+	}
+}
+
+func TestShouldUseSessionMatchesUpload(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend Backend
+		session bool
+	}{
+		{"Live", BackendLive, false},
+		{"Graph", BackendGraph, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sessions, puts int
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/createUploadSession"):
+					sessions++
+					json.NewEncoder(w).Encode(uploadSession{UploadUrl: "http://" + r.Host + "/session"})
+				case r.URL.Path == "/session":
+					w.WriteHeader(http.StatusCreated)
+					w.Write([]byte(`{"id":"1","name":"a.txt","file":{}}`))
+				case r.Method == "PUT":
+					puts++
+					w.WriteHeader(http.StatusCreated)
+					json.NewEncoder(w).Encode(NodeInfo{Id: "file.1", Name: "a.txt", Type: "file"})
+				default:
+					t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+				}
+			})
+			var d *OneDrive
+			if tt.backend == BackendGraph {
+				d = newGraphTestClient(t, handler)
+			} else {
+				d = newTestClient(t, handler)
+			}
+			d.SimpleUploadMaxBytes = 1
+
+			if got := d.ShouldUseSession(5); got != tt.session {
+				t.Errorf("ShouldUseSession(5) = %v, want %v", got, tt.session)
+			}
+			if _, err := d.UploadOverwrite("root", "a.txt", true, strings.NewReader("hello")); err != nil {
+				t.Fatal(err)
+			}
+			if (sessions == 1) != tt.session || (puts == 1) == tt.session {
+				t.Errorf("got %d sessions and %d single PUTs, want session %v", sessions, puts, tt.session)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package onedriveclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/koofr/go-httpclient"
+	"io"
+	"net/http"
+	"time"
+)
+
+// UploadSessionInfo identifies a resumable upload session. It can be
+// persisted, e.g. as JSON, to resume the upload with ResumeUploadSession
+// after a restart. The upload URL is pre-authenticated and should be kept
+// as secret as the access token.
+type UploadSessionInfo struct {
+	UploadURL string    `json:"uploadUrl"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// NextExpectedRanges are the byte ranges the server still expects,
+	// e.g. "0-" for a new session, as of when the session was created.
+	NextExpectedRanges []string `json:"nextExpectedRanges"`
+	// DirId and Name are the folder and name the session uploads to.
+	DirId string `json:"dirId"`
+	Name  string `json:"name"`
+}
+
+func (d *OneDrive) CreateUploadSession(dirId string, name string, conflict ConflictBehavior) (session UploadSessionInfo, err error) {
+	return d.CreateUploadSessionContext(context.Background(), dirId, name, conflict)
+}
+
+// CreateUploadSessionContext creates an upload session for dirId/name
+// without sending any content. Send it with ResumeUploadSession. Together
+// they replace UploadSession for callers that need a handle on the session,
+// e.g. to persist it or cancel it with CancelUploadSession.
+func (d *OneDrive) CreateUploadSessionContext(ctx context.Context, dirId string, name string, conflict ConflictBehavior) (session UploadSessionInfo, err error) {
+	defer wrapOp(&err, "CreateUploadSession", dirId+"/"+name)
+	s, err := d.createUploadSession(ctx, dirId, name, conflict)
+	if err != nil {
+		return
+	}
+	session.UploadURL = s.UploadUrl
+	session.ExpiresAt = parseTime(s.ExpirationDateTime)
+	session.NextExpectedRanges = s.NextExpectedRanges
+	session.DirId = dirId
+	session.Name = name
+	return
+}
+
+func (d *OneDrive) ResumeUploadSession(session UploadSessionInfo, content io.ReadSeeker) (info NodeInfo, err error) {
+	return d.ResumeUploadSessionContext(context.Background(), session, content)
+}
+
+func (d *OneDrive) ResumeUploadSessionWithProgress(session UploadSessionInfo, content io.ReadSeeker, progress ProgressFunc) (info NodeInfo, err error) {
+	return d.ResumeUploadSessionWithProgressContext(context.Background(), session, content, progress)
+}
+
+// ResumeUploadSessionContext uploads content to the session, starting at
+// the first byte the server still expects, so that no content it already
+// has is sent again. The size of the file is the size of content. If the
+// server already has all of it, nothing is sent and the info of the file
+// is looked up by session.DirId and session.Name. If the session has
+// expired or was canceled, the returned error matches
+// ErrUploadSessionExpired.
+func (d *OneDrive) ResumeUploadSessionContext(ctx context.Context, session UploadSessionInfo, content io.ReadSeeker) (info NodeInfo, err error) {
+	defer wrapOp(&err, "ResumeUploadSession", session.DirId+"/"+session.Name)
+	return d.ResumeUploadSessionWithProgressContext(ctx, session, content, nil)
+}
+
+// ResumeUploadSessionWithProgressContext is like ResumeUploadSessionContext
+// but calls progress after every chunk the server confirms.
+func (d *OneDrive) ResumeUploadSessionWithProgressContext(ctx context.Context, session UploadSessionInfo, content io.ReadSeeker, progress ProgressFunc) (info NodeInfo, err error) {
+	// the session URL is a credential, so it is not part of the error
+	defer wrapOp(&err, "ResumeUploadSessionWithProgress", session.DirId+"/"+session.Name)
+	size, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return
+	}
+
+	next, err := d.uploadSessionNext(ctx, session.UploadURL)
+	if err == nil && next > size {
+		err = fmt.Errorf("Upload session expects byte %d of %d", next, size)
+	}
+	if err != nil {
+		return NodeInfo{}, sessionExpired(err)
+	}
+	if next == size {
+		return d.committedItem(ctx, session.DirId, session.Name)
+	}
+	if _, err = content.Seek(next, io.SeekStart); err != nil {
+		return
+	}
+
+	info, err = d.sendChunks(ctx, session.UploadURL, session.DirId, session.Name, content, next, size, progress)
+	return info, sessionExpired(err)
+}
+
+func (d *OneDrive) CancelUploadSession(sessionURL string) (err error) {
+	return d.CancelUploadSessionContext(context.Background(), sessionURL)
+}
+
+// CancelUploadSessionContext cancels the session and discards the content
+// uploaded so far.
+func (d *OneDrive) CancelUploadSessionContext(ctx context.Context, sessionURL string) (err error) {
+	defer wrapOp(&err, "CancelUploadSession", "")
+	req := &httpclient.RequestData{
+		Context:        ctx,
+		Method:         "DELETE",
+		FullURL:        sessionURL,
+		ExpectedStatus: []int{204},
+		RespConsume:    true,
+	}
+	_, err = d.request(ctx, d.ContentClient, req)
+	return sessionExpired(err)
+}
+
+// sessionExpired maps the server's answer to requests for a session
+// that no longer exists to ErrUploadSessionExpired.
+func sessionExpired(err error) error {
+	if isStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("%w: %w", ErrUploadSessionExpired, opCause(err))
+	}
+	return err
+}
@@ -0,0 +1,168 @@
+package onedriveclient
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sync"
+)
+
+// SkipDir can be returned by a WalkFunc to skip the folder it was called
+// for. Returned for a file, it skips the remaining files of its folder.
+var SkipDir = errors.New("Skip this directory")
+
+// WalkFunc is called by Walk for every node. pth is the path of the node
+// relative to the walk's root, which is visited first with pth "". If
+// listing a folder fails, fn is called a second time for the folder with
+// the error; returning nil then continues with the rest of the tree.
+type WalkFunc func(pth string, info NodeInfo, err error) error
+
+func (d *OneDrive) Walk(id string, fn WalkFunc) (err error) {
+	return d.WalkContext(context.Background(), id, fn)
+}
+
+// WalkContext walks the tree rooted at id depth-first, calling fn for every
+// node. Children are visited in the order the server lists them.
+func (d *OneDrive) WalkContext(ctx context.Context, id string, fn WalkFunc) (err error) {
+	info, err := d.NodeInfoContext(ctx, id)
+	if err != nil {
+		err = fn("", info, err)
+	} else {
+		err = d.walk(ctx, "", info, fn)
+	}
+	if err == SkipDir {
+		err = nil
+	}
+	return
+}
+
+func (d *OneDrive) walk(ctx context.Context, pth string, info NodeInfo, fn WalkFunc) (err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = fn(pth, info, nil); err != nil || !info.IsDir() {
+		return
+	}
+
+	files, err := d.NodeFilesContext(ctx, info.Id)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		if err = fn(pth, info, err); err == SkipDir {
+			err = nil
+		}
+		return
+	}
+
+	for _, file := range files {
+		err = d.walk(ctx, path.Join(pth, file.Name), file, fn)
+		if err == SkipDir {
+			if file.IsDir() {
+				err = nil
+				continue
+			}
+			return nil
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (d *OneDrive) WalkParallel(id string, concurrency int, fn WalkFunc) (err error) {
+	return d.WalkParallelContext(context.Background(), id, concurrency, fn)
+}
+
+// WalkParallelContext walks the tree rooted at id like WalkContext, but
+// lists up to concurrency folders at a time (d.Concurrency if concurrency
+// is not positive). Nodes are visited in no particular order, each at most
+// once; calls to fn are serialized. SkipDir returned for a file is ignored.
+// The first error returned by fn stops the walk and is returned.
+func (d *OneDrive) WalkParallelContext(ctx context.Context, id string, concurrency int, fn WalkFunc) (err error) {
+	if concurrency <= 0 {
+		concurrency = d.concurrency()
+	}
+
+	root, err := d.NodeInfoContext(ctx, id)
+	if err != nil {
+		if err = fn("", root, err); err == SkipDir {
+			err = nil
+		}
+		return
+	}
+
+	walkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// mu guards fn, visited and err
+	var mu sync.Mutex
+	visited := make(map[string]bool)
+
+	call := func(pth string, info NodeInfo, lerr error) (descend bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			return false
+		}
+		if lerr == nil {
+			if visited[info.Id] {
+				return false
+			}
+			visited[info.Id] = true
+		}
+		ferr := fn(pth, info, lerr)
+		if ferr == SkipDir {
+			return false
+		}
+		if ferr != nil {
+			err = ferr
+			cancel()
+			return false
+		}
+		return lerr == nil && info.IsDir()
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	var list func(pth string, info NodeInfo)
+	list = func(pth string, info NodeInfo) {
+		defer wg.Done()
+
+		select {
+		case sem <- struct{}{}:
+		case <-walkCtx.Done():
+			return
+		}
+		files, lerr := d.NodeFilesContext(walkCtx, info.Id)
+		<-sem
+
+		if lerr != nil {
+			if walkCtx.Err() == nil {
+				call(pth, info, lerr)
+			}
+			return
+		}
+
+		for _, file := range files {
+			filePth := path.Join(pth, file.Name)
+			if call(filePth, file, nil) {
+				wg.Add(1)
+				go list(filePth, file)
+			}
+		}
+	}
+
+	if call("", root, nil) {
+		wg.Add(1)
+		go list("", root)
+	}
+	wg.Wait()
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return
+}